@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	_ "github.com/jolt9dev/go-jolt9/pkg/expr/celexpr"
+	_ "github.com/jolt9dev/go-jolt9/pkg/expr/tmplexpr"
+	"github.com/jolt9dev/go-jolt9/pkg/tasks"
+	"github.com/spf13/cobra"
+)
+
+var expressions string
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate <workflow>",
+	Short: "Check a workflow file without running it",
+	Long: `Validate loads a workflow file, compiling every expression, and reports
+needs that name unknown tasks, with suggestions for likely typos, and
+dependency cycles. Dependencies inferred from outputs references are
+included in the checks and any that cannot be satisfied are reported as
+warnings.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workflow, err := tasks.LoadWorkflow(args[0], expressions)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		for _, warning := range workflow.Warnings {
+			fmt.Fprintf(out, "warning: %s\n", warning)
+		}
+
+		problems := 0
+		if missing := workflow.Tasks.MissingDependencies(); missing != nil {
+			fmt.Fprintln(out, missing.Error())
+			problems += len(missing.Tasks)
+		}
+
+		if cycles := workflow.Tasks.CyclicDependencies(); cycles != nil {
+			fmt.Fprintln(out, cycles.Error())
+			problems += len(cycles.Cycles)
+		}
+
+		if problems > 0 {
+			return errors.New("workflow is not valid")
+		}
+
+		fmt.Fprintf(out, "%s is valid: %d tasks\n", args[0], workflow.Tasks.Len())
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&expressions, "expressions", "",
+		"expression dialect for the workflow, one of "+strings.Join(expr.Dialects(), ", ")+" (default is the workflow's expressions key or "+expr.DefaultDialect+")")
+	rootCmd.AddCommand(validateCmd)
+}
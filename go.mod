@@ -2,10 +2,13 @@ module github.com/jolt9dev/go-jolt9
 
 go 1.23.4
 
+require (
+	github.com/jolt9dev/go-xstrings v0.0.0
+	github.com/spf13/cobra v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/jolt9dev/go-xstrings v0.0.0 // indirect
-	github.com/spf13/cobra v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
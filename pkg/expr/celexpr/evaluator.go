@@ -0,0 +1,104 @@
+package celexpr
+
+import (
+	"unicode/utf8"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"github.com/jolt9dev/go-jolt9/pkg/expr/j9expr"
+)
+
+const Dialect = "cel"
+
+// Syntax is the CEL-style syntax: `${ }` delimiters, arithmetic, the ternary
+// and `in` operators, backslash escapes in strings and method call sugar such
+// as `name.startsWith('v')`. As `${` is also shell parameter expansion,
+// scripts written for this dialect escape it as `$${HOME}`; an unescaped
+// `${HOME}` fails to compile, as HOME is not one of Roots.
+var Syntax = j9expr.Syntax{Open: "${", Close: "}", CEL: true, Roots: Roots}
+
+// Roots are the contexts a task's expressions can read.
+var Roots = []string{"env", "vars", "outputs", "needs", "matrix", "secrets", "attempt", j9expr.ContextKey}
+
+func init() {
+	expr.RegisterDialect(Dialect, func() expr.Dialect {
+		return New()
+	})
+}
+
+// New creates a j9expr evaluator for the CEL-style syntax with the CEL
+// conversion functions added to the core function library.
+func New() *j9expr.Evaluator {
+	e := j9expr.NewSyntax(Syntax)
+	e.Register("size", fnSize)
+	e.Register("has", fnHas)
+	e.Register("string", fnString)
+	e.Register("int", fnInt)
+	e.Register("double", fnDouble)
+	return e
+}
+
+func fnSize(call *j9expr.Call, args []interface{}) (interface{}, error) {
+	if err := j9expr.ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	switch v := args[0].(type) {
+	case string:
+		return int64(utf8.RuneCountInString(v)), nil
+	case []interface{}:
+		return int64(len(v)), nil
+	case map[string]interface{}:
+		return int64(len(v)), nil
+	}
+
+	return nil, &j9expr.ArgumentError{Index: 0, Message: "expected string, array or object but got " + expr.TypeName(args[0])}
+}
+
+// fnHas reports whether a field is present. Missing properties evaluate to
+// null, so `has(outputs.build)` is a null check on its argument.
+func fnHas(call *j9expr.Call, args []interface{}) (interface{}, error) {
+	if err := j9expr.ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	return args[0] != nil, nil
+}
+
+func fnString(call *j9expr.Call, args []interface{}) (interface{}, error) {
+	if err := j9expr.ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	return expr.ToString(args[0]), nil
+}
+
+func fnInt(call *j9expr.Call, args []interface{}) (interface{}, error) {
+	if err := j9expr.ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	value := args[0]
+	if f, ok := value.(float64); ok {
+		value = float64(int64(f))
+	}
+
+	i, err := expr.Coerce(value, "int64")
+	if err != nil {
+		return nil, &j9expr.ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	return i, nil
+}
+
+func fnDouble(call *j9expr.Call, args []interface{}) (interface{}, error) {
+	if err := j9expr.ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	f, err := expr.Coerce(args[0], "float64")
+	if err != nil {
+		return nil, &j9expr.ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	return f, nil
+}
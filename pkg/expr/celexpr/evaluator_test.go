@@ -0,0 +1,70 @@
+package celexpr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvalValue(t *testing.T) {
+	data := map[string]interface{}{
+		"env":  map[string]interface{}{"NAME": "v1.2"},
+		"vars": map[string]interface{}{"list": []interface{}{int64(1), int64(2), int64(3)}},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		{"arithmetic", "${1 + 2 * 3}", int64(7)},
+		{"integer division", "${7 / 2}", int64(3)},
+		{"modulo", "${7 % 4}", int64(3)},
+		{"negate", "${-size(vars.list)}", int64(-3)},
+		{"ternary", "${size(vars.list) > 2 ? 'many' : 'few'}", "many"},
+		{"in", "${2 in vars.list}", true},
+		{"method call", "${env.NAME.startsWith('v')}", true},
+		{"backslash escape", "${'a\\tb'}", "a\tb"},
+		{"has", "${has(env.NOPE)}", false},
+		{"int", "${int('42')}", int64(42)},
+		{"double", "${double(1)}", 1.0},
+		{"string", "${string(1.5)}", "1.5"},
+		{"interpolation", "echo ${env.NAME}", "echo v1.2"},
+		{"escaped shell expansion", "echo $${HOME} $${env.NAME}", "echo ${HOME} ${env.NAME}"},
+		{"escape next to an expression", "$${A}${env.NAME}", "${A}v1.2"},
+		{"dollar without brace", "echo $$ $HOME", "echo $$ $HOME"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.EvalValue(tt.expr, data)
+			if err != nil {
+				t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalValue(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownNames(t *testing.T) {
+	tests := []string{
+		"echo ${HOME}",
+		"${PATH}:/usr/local/bin",
+		"${size(list)}",
+		"${env.NAME == other}",
+	}
+
+	e := New()
+	for _, src := range tests {
+		if _, err := e.Compile(src); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an unknown name error", src)
+		}
+
+		if _, err := e.EvalValue(src, map[string]interface{}{"HOME": "/root"}); err == nil {
+			t.Errorf("EvalValue(%q) succeeded, want an unknown name error", src)
+		}
+	}
+}
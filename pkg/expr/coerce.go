@@ -0,0 +1,352 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type TypeMismatchError struct {
+	Expected string
+	Actual   interface{}
+	Reason   string
+}
+
+func (e *TypeMismatchError) Error() string {
+	actual := "null"
+	if e.Actual != nil {
+		actual = fmt.Sprintf("%s (%s)", TypeName(e.Actual), ToString(e.Actual))
+	}
+
+	if e.Reason != "" {
+		return fmt.Sprintf("expected %s but got %s: %s", e.Expected, actual, e.Reason)
+	}
+
+	return fmt.Sprintf("expected %s but got %s", e.Expected, actual)
+}
+
+// TypeName returns the expression type name of a native value.
+func TypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return "number"
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// ToString formats a native value the way it is spliced into a string.
+func ToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return ToString(float64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func toInt64(value interface{}, bits int) (int64, bool, string) {
+	var i int64
+	switch v := value.(type) {
+	case int:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint32:
+		i = int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false, "out of range"
+		}
+		i = int64(v)
+	case float32:
+		return toInt64(float64(v), bits)
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false, "not an integer"
+		}
+		if v > math.MaxInt64 || v < math.MinInt64 {
+			return 0, false, "out of range"
+		}
+		i = int64(v)
+	case string:
+		str := strings.TrimSpace(v)
+		n, err := strconv.ParseInt(str, 10, bits)
+		if err != nil {
+			return 0, false, "not a valid integer"
+		}
+		return n, true, ""
+	default:
+		return 0, false, ""
+	}
+
+	if bits < 64 {
+		limit := int64(1) << (bits - 1)
+		if i >= limit || i < -limit {
+			return 0, false, "out of range"
+		}
+	}
+
+	return i, true, ""
+}
+
+func toUint64(value interface{}, bits int) (uint64, bool, string) {
+	if s, ok := value.(string); ok {
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, bits)
+		if err != nil {
+			return 0, false, "not a valid unsigned integer"
+		}
+		return n, true, ""
+	}
+
+	if u, ok := value.(uint64); ok {
+		if bits < 64 && u >= uint64(1)<<bits {
+			return 0, false, "out of range"
+		}
+		return u, true, ""
+	}
+
+	i, ok, reason := toInt64(value, 64)
+	if !ok {
+		return 0, false, reason
+	}
+
+	if i < 0 {
+		return 0, false, "negative value"
+	}
+
+	if bits < 64 && uint64(i) >= uint64(1)<<bits {
+		return 0, false, "out of range"
+	}
+
+	return uint64(i), true, ""
+}
+
+func toFloat64(value interface{}) (float64, bool, string) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true, ""
+	case int32:
+		return float64(v), true, ""
+	case int64:
+		return float64(v), true, ""
+	case uint32:
+		return float64(v), true, ""
+	case uint64:
+		return float64(v), true, ""
+	case float32:
+		return float64(v), true, ""
+	case float64:
+		return v, true, ""
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false, "not a valid number"
+		}
+		return f, true, ""
+	}
+
+	return 0, false, ""
+}
+
+// Coerce converts a native value produced by an evaluator into the Go type
+// named by typ. Strings are parsed so that string-only evaluators keep
+// working. A null or empty string yields the zero value of the type.
+func Coerce(value interface{}, typ string) (interface{}, error) {
+	if s, ok := value.(string); ok && strings.TrimSpace(s) == "" && typ != "string" {
+		value = nil
+	}
+
+	switch typ {
+	case "string":
+		return ToString(value), nil
+
+	case "bool":
+		switch v := value.(type) {
+		case nil:
+			return false, nil
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, &TypeMismatchError{Expected: typ, Actual: value}
+			}
+			return b, nil
+		}
+
+		return nil, &TypeMismatchError{Expected: typ, Actual: value}
+
+	case "int32", "int", "int64":
+		bits := 64
+		if typ == "int32" {
+			bits = 32
+		}
+
+		if value == nil {
+			if bits == 32 {
+				return int32(0), nil
+			}
+			return int64(0), nil
+		}
+
+		i, ok, reason := toInt64(value, bits)
+		if !ok {
+			return nil, &TypeMismatchError{Expected: typ, Actual: value, Reason: reason}
+		}
+
+		if bits == 32 {
+			return int32(i), nil
+		}
+		return i, nil
+
+	case "uint32", "uint", "uint64":
+		bits := 64
+		if typ == "uint32" {
+			bits = 32
+		}
+
+		if value == nil {
+			if bits == 32 {
+				return uint32(0), nil
+			}
+			return uint64(0), nil
+		}
+
+		u, ok, reason := toUint64(value, bits)
+		if !ok {
+			return nil, &TypeMismatchError{Expected: typ, Actual: value, Reason: reason}
+		}
+
+		if bits == 32 {
+			return uint32(u), nil
+		}
+		return u, nil
+
+	case "array":
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case []interface{}:
+			return v, nil
+		case string:
+			var list []interface{}
+			if err := json.Unmarshal([]byte(v), &list); err != nil {
+				return nil, &TypeMismatchError{Expected: typ, Actual: value, Reason: "not a valid JSON array"}
+			}
+			return list, nil
+		}
+
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			list := make([]interface{}, rv.Len())
+			for i := range list {
+				list[i] = rv.Index(i).Interface()
+			}
+			return list, nil
+		}
+
+		return nil, &TypeMismatchError{Expected: typ, Actual: value}
+
+	case "object":
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case map[string]interface{}:
+			return v, nil
+		case string:
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(v), &obj); err != nil {
+				return nil, &TypeMismatchError{Expected: typ, Actual: value, Reason: "not a valid JSON object"}
+			}
+			return obj, nil
+		}
+
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+			obj := make(map[string]interface{}, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				obj[iter.Key().String()] = iter.Value().Interface()
+			}
+			return obj, nil
+		}
+
+		return nil, &TypeMismatchError{Expected: typ, Actual: value}
+
+	case "float32", "float64", "float", "number":
+		if value == nil {
+			if typ == "float32" {
+				return float32(0), nil
+			}
+			return float64(0), nil
+		}
+
+		f, ok, reason := toFloat64(value)
+		if !ok {
+			return nil, &TypeMismatchError{Expected: typ, Actual: value, Reason: reason}
+		}
+
+		if typ == "float32" {
+			if math.Abs(f) > math.MaxFloat32 {
+				return nil, &TypeMismatchError{Expected: typ, Actual: value, Reason: "out of range"}
+			}
+			return float32(f), nil
+		}
+		return f, nil
+	}
+
+	return value, nil
+}
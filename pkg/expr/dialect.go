@@ -0,0 +1,69 @@
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const DefaultDialect = "j9"
+
+// Dialect is an expression language that can be selected per workflow.
+type Dialect interface {
+	Evaluator
+	Compiler
+
+	// IsExpression reports whether value contains an expression in this
+	// dialect's syntax; values that do not are treated as literals.
+	IsExpression(value string) bool
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = make(map[string]func() Dialect)
+)
+
+// RegisterDialect makes a dialect available by name. The factory is called
+// for every workflow so that each one gets its own evaluator and function
+// library.
+func RegisterDialect(name string, factory func() Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+
+	if factory == nil {
+		panic("expr: RegisterDialect factory is nil")
+	}
+
+	dialects[name] = factory
+}
+
+// NewDialect creates an evaluator for the named dialect. An empty name selects
+// DefaultDialect.
+func NewDialect(name string) (Dialect, error) {
+	if name == "" {
+		name = DefaultDialect
+	}
+
+	dialectsMu.RLock()
+	factory, ok := dialects[name]
+	dialectsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown expression dialect %q, expected one of %v", name, Dialects())
+	}
+
+	return factory(), nil
+}
+
+func Dialects() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
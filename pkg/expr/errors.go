@@ -0,0 +1,37 @@
+package expr
+
+import "fmt"
+
+// SyntaxError is returned by compilers for malformed expressions. Offset is a
+// byte offset into Source.
+type SyntaxError struct {
+	Source  string
+	Offset  int
+	Message string
+}
+
+// Position returns the 1-based line and column of the error within the
+// expression source.
+func (e *SyntaxError) Position() (int, int) {
+	return Position(e.Source, e.Offset)
+}
+
+func (e *SyntaxError) Error() string {
+	line, column := e.Position()
+	return fmt.Sprintf("expression syntax error: %s on line %d at column %d", e.Message, line, column)
+}
+
+// Position converts a byte offset into a 1-based line and column.
+func Position(source string, offset int) (int, int) {
+	line, column := 1, 1
+	for i := 0; i < offset && i < len(source); i++ {
+		if source[i] == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+
+	return line, column
+}
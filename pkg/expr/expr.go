@@ -1,177 +1,356 @@
 package expr
 
 import (
-	"strconv"
-	"strings"
-)
+	"fmt"
+	"sort"
 
-type Expression struct {
-	Raw         string
-	ValueString string
-	Value       interface{}
-	IsEvaluated bool
-	Type        string
-}
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
 
 type Evaluator interface {
 	Eval(template string, ctx map[string]interface{}) (string, error)
 }
 
-func (e *Expression) Eval(evaluator Evaluator, ctx map[string]interface{}) error {
-	if !e.IsEvaluated {
-		v, err := evaluator.Eval(e.Raw, ctx)
-		if err != nil {
-			return err
-		}
+// ValueEvaluator is implemented by evaluators that return native values
+// (bool, numbers, strings, lists and maps) instead of strings.
+type ValueEvaluator interface {
+	EvalValue(template string, ctx map[string]interface{}) (interface{}, error)
+}
 
-		e.ValueString = v
-		e.IsEvaluated = true
-		switch e.Type {
-		case "string":
-			e.Value = e.ValueString
-		case "uint32":
-			e.Value = uint32(0)
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				i64, err := strconv.ParseInt(str, 10, 32)
-				if err != nil {
-					return err
-				}
-
-				e.Value = uint32(i64)
-			}
-		case "int32":
-			e.Value = int32(0)
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				i64, err := strconv.ParseInt(str, 10, 32)
-				if err != nil {
-					return err
-				}
-
-				e.Value = int32(i64)
-			}
-		case "int":
-			fallthrough
-		case "int64":
-			e.Value = int64(0)
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				i64, err := strconv.ParseInt(str, 10, 64)
-				if err != nil {
-					return err
-				}
-
-				e.Value = i64
-			}
-		case "uint":
-			fallthrough
-		case "uint64":
-			e.Value = uint64(0)
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				i64, err := strconv.ParseInt(str, 10, 64)
-				if err != nil {
-					return err
-				}
-
-				e.Value = uint64(i64)
-			}
-
-		case "float32":
-			e.Value = float32(0)
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				f64, err := strconv.ParseFloat(str, 32)
-				if err != nil {
-					return err
-				}
-
-				e.Value = float32(f64)
-			}
-		case "float64":
-			e.Value = float64(0)
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				f64, err := strconv.ParseFloat(str, 64)
-				if err != nil {
-					return err
-				}
-
-				e.Value = f64
-			}
-
-		case "bool":
-			e.Value = false
-			str := strings.TrimSpace(e.ValueString)
-			if str != "" {
-				b, err := strconv.ParseBool(str)
-				if err != nil {
-					return err
-				}
-
-				e.Value = b
-			}
-
-		default:
-		}
-	}
+// Compiler is implemented by evaluators that can parse an expression once so
+// that it can be validated up front and evaluated many times.
+type Compiler interface {
+	Compile(template string) (interface{}, error)
+}
 
-	return nil
+// CompiledEvaluator evaluates the result of Compiler.Compile.
+type CompiledEvaluator interface {
+	EvalCompiled(compiled interface{}, ctx map[string]interface{}) (interface{}, error)
 }
 
-func (s *Expression) String() string {
-	if !s.IsEvaluated || s.Type != "string" {
-		return ""
-	}
+// Referencer is implemented by compiled expressions that can report the
+// context paths they read, such as ["outputs", "build", "artifact"].
+type Referencer interface {
+	References() [][]string
+}
 
-	return s.ValueString
+// Caller is implemented by compiled expressions that can report the names of
+// the functions they call.
+type Caller interface {
+	Calls() []string
 }
 
-func (s *Expression) Uint32() uint32 {
-	if !s.IsEvaluated || s.Type != "uint32" {
-		return 0
-	}
+type stringEvaluator struct {
+	evaluator Evaluator
+}
 
-	return s.Value.(uint32)
+func (s *stringEvaluator) EvalValue(template string, ctx map[string]interface{}) (interface{}, error) {
+	return s.evaluator.Eval(template, ctx)
 }
 
-func (s *Expression) Uint64() uint64 {
-	if !s.IsEvaluated || (s.Type != "uint64" && s.Type != "uint") {
-		return 0
+// AsValueEvaluator returns evaluator as a ValueEvaluator, wrapping string-only
+// evaluators so their results are parsed by Coerce.
+func AsValueEvaluator(evaluator Evaluator) ValueEvaluator {
+	if ve, ok := evaluator.(ValueEvaluator); ok {
+		return ve
 	}
 
-	return s.Value.(uint64)
+	return &stringEvaluator{evaluator: evaluator}
 }
 
-func (s *Expression) Int32() int32 {
-	if !s.IsEvaluated || s.Type != "int32" {
-		return 0
+// Typed is an expression whose result is coerced to T. It is either a
+// literal, which is evaluated from the start, or a raw expression, optionally
+// compiled, that is evaluated on demand.
+type Typed[T any] struct {
+	raw       string
+	compiled  interface{}
+	value     T
+	evaluated bool
+	literal   bool
+	tainted   bool
+	err       error
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
 
-	return s.Value.(int32)
+	sort.Strings(keys)
+	return keys
 }
 
-func (s *Expression) Int64() int64 {
-	if !s.IsEvaluated || (s.Type != "int64" && s.Type != "int") {
-		return 0
+func typeOf[T any]() string {
+	var zero T
+	switch any(zero).(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int32:
+		return "int32"
+	case int64:
+		return "int64"
+	case uint32:
+		return "uint32"
+	case uint64:
+		return "uint64"
+	case float32:
+		return "float32"
+	case float64:
+		return "float64"
 	}
 
-	return s.Value.(int64)
+	return "any"
+}
+
+func (e *Typed[T]) Type() string {
+	return typeOf[T]()
+}
+
+func (e *Typed[T]) Raw() string {
+	return e.raw
+}
+
+func (e *Typed[T]) IsEvaluated() bool {
+	return e.evaluated
+}
+
+func (e *Typed[T]) Compiled() interface{} {
+	return e.compiled
+}
+
+// IsTainted reports whether the evaluated value may hold secret material and
+// must be masked wherever it is displayed.
+func (e *Typed[T]) IsTainted() bool {
+	return e.tainted
 }
 
-func (s *Expression) Float32() float32 {
-	if !s.IsEvaluated || s.Type != "float32" {
-		return 0
+func (e *Typed[T]) Taint() {
+	e.tainted = true
+}
+
+// Error returns the error recorded by the last call to Eval, if any.
+func (e *Typed[T]) Error() error {
+	return e.err
+}
+
+func (e *Typed[T]) Value() T {
+	return e.value
+}
+
+// SetValue makes the expression a literal holding value.
+func (e *Typed[T]) SetValue(value T) {
+	e.raw = ToString(value)
+	e.compiled = nil
+	e.value = value
+	e.evaluated = true
+	e.literal = true
+	e.tainted = false
+	e.err = nil
+}
+
+// SetRaw makes the expression a deferred expression. compiled may be nil, in
+// which case the raw text is handed to the evaluator.
+func (e *Typed[T]) SetRaw(raw string, compiled interface{}) {
+	var zero T
+	e.raw = raw
+	e.compiled = compiled
+	e.value = zero
+	e.evaluated = false
+	e.literal = false
+	e.tainted = false
+	e.err = nil
+}
+
+// Copy returns the expression without the result of any previous Eval, so
+// that the copy can be evaluated with other data. Trees are copied down to
+// their leaves; literals keep their value.
+func (e *Typed[T]) Copy() Typed[T] {
+	if e.literal {
+		return Typed[T]{raw: e.raw, value: e.value, evaluated: true, literal: true}
+	}
+
+	compiled := e.compiled
+	if tree, ok := compiled.(*Tree); ok {
+		compiled = tree.Copy()
 	}
 
-	return s.Value.(float32)
+	return Typed[T]{raw: e.raw, compiled: compiled}
 }
 
-func (s *Expression) Float64() float64 {
-	if !s.IsEvaluated || s.Type != "float64" {
-		return 0
+// SetTree makes the expression a structured value whose leaves are evaluated
+// individually. Compiled returns the tree.
+func (e *Typed[T]) SetTree(tree *Tree) {
+	e.SetRaw(tree.source(), tree)
+}
+
+// Eval evaluates the expression against ctx and keeps the result for Value.
+// Literals keep their value; other expressions are evaluated again on every
+// call, so that one compiled expression can be evaluated with other data.
+func (e *Typed[T]) Eval(evaluator Evaluator, ctx map[string]interface{}) error {
+	if e.literal {
+		return nil
+	}
+
+	var zero T
+	e.value = zero
+	e.evaluated = false
+	e.tainted = false
+
+	var v interface{}
+	var err error
+	if tree, ok := e.compiled.(*Tree); ok {
+		v, err = tree.Eval(evaluator, ctx)
+	} else if ce, ok := evaluator.(CompiledEvaluator); ok && e.compiled != nil {
+		v, err = ce.EvalCompiled(e.compiled, ctx)
+	} else {
+		v, err = AsValueEvaluator(evaluator).EvalValue(e.raw, ctx)
+	}
+	if err != nil {
+		e.err = err
+		return err
 	}
 
-	return s.Value.(float64)
+	value, err := Coerce(v, e.Type())
+	if err != nil {
+		e.err = fmt.Errorf("expression %q: %w", e.raw, err)
+		return e.err
+	}
+
+	var typed T
+	if value != nil {
+		t, ok := value.(T)
+		if !ok {
+			e.err = fmt.Errorf("expression %q: %w", e.raw, &TypeMismatchError{Expected: e.Type(), Actual: value})
+			return e.err
+		}
+		typed = t
+	}
+
+	e.value = typed
+	e.evaluated = true
+	e.err = nil
+	return nil
 }
+
+type StringExpression struct {
+	Typed[string]
+}
+
+type BoolExpression struct {
+	Typed[bool]
+}
+
+type Int32Expression struct {
+	Typed[int32]
+}
+
+type Int64Expression struct {
+	Typed[int64]
+}
+
+type Uint32Expression struct {
+	Typed[uint32]
+}
+
+type Float32Expression struct {
+	Typed[float32]
+}
+
+type Float64Expression struct {
+	Typed[float64]
+}
+
+// AnyExpression keeps whatever native value the evaluator produced.
+type AnyExpression struct {
+	Typed[interface{}]
+}
+
+func NewStringExpression(value string) *StringExpression {
+	e := &StringExpression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewBoolExpression(value bool) *BoolExpression {
+	e := &BoolExpression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewInt32Expression(value int32) *Int32Expression {
+	e := &Int32Expression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewInt64Expression(value int64) *Int64Expression {
+	e := &Int64Expression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewUint32Expression(value uint32) *Uint32Expression {
+	e := &Uint32Expression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewFloat32Expression(value float32) *Float32Expression {
+	e := &Float32Expression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewFloat64Expression(value float64) *Float64Expression {
+	e := &Float64Expression{}
+	e.SetValue(value)
+	return e
+}
+
+func NewAnyExpression(value interface{}) *AnyExpression {
+	e := &AnyExpression{}
+	e.SetValue(value)
+	return e
+}
+
+func (e *StringExpression) String() string {
+	return e.value
+}
+
+func (e *BoolExpression) Bool() bool {
+	return e.value
+}
+
+func (e *Int32Expression) Int32() int32 {
+	return e.value
+}
+
+func (e *Int64Expression) Int64() int64 {
+	return e.value
+}
+
+func (e *Uint32Expression) Uint32() uint32 {
+	return e.value
+}
+
+func (e *Float32Expression) Float32() float32 {
+	return e.value
+}
+
+func (e *Float64Expression) Float64() float64 {
+	return e.value
+}
+
+var (
+	_ primitives.StringExpression  = (*StringExpression)(nil)
+	_ primitives.BoolExpression    = (*BoolExpression)(nil)
+	_ primitives.Int32Expression   = (*Int32Expression)(nil)
+	_ primitives.Int64Expression   = (*Int64Expression)(nil)
+	_ primitives.Uint32Expression  = (*Uint32Expression)(nil)
+	_ primitives.Float32Expression = (*Float32Expression)(nil)
+	_ primitives.Float64Expression = (*Float64Expression)(nil)
+	_ primitives.Expression        = (*AnyExpression)(nil)
+)
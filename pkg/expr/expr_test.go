@@ -0,0 +1,95 @@
+package expr
+
+import "testing"
+
+type countingEvaluator struct {
+	calls int
+}
+
+func (c *countingEvaluator) Eval(template string, ctx map[string]interface{}) (string, error) {
+	c.calls++
+	return ToString(ctx["value"]), nil
+}
+
+func TestTypedEvalAgain(t *testing.T) {
+	evaluator := &countingEvaluator{}
+	e := &StringExpression{}
+	e.SetRaw("value", nil)
+
+	for _, want := range []string{"a", "b"} {
+		if err := e.Eval(evaluator, map[string]interface{}{"value": want}); err != nil {
+			t.Fatal(err)
+		}
+
+		if e.String() != want {
+			t.Errorf("got %q, want %q", e.String(), want)
+		}
+	}
+
+	if evaluator.calls != 2 {
+		t.Errorf("got %d evaluations, want 2", evaluator.calls)
+	}
+}
+
+func TestTypedEvalLiteral(t *testing.T) {
+	evaluator := &countingEvaluator{}
+	e := NewStringExpression("fixed")
+	if err := e.Eval(evaluator, map[string]interface{}{"value": "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.String() != "fixed" || evaluator.calls != 0 {
+		t.Errorf("got %q after %d evaluations, want the literal unevaluated", e.String(), evaluator.calls)
+	}
+}
+
+func TestTypedEvalClearsTaint(t *testing.T) {
+	e := &StringExpression{}
+	e.SetRaw("value", nil)
+	if err := e.Eval(&countingEvaluator{}, map[string]interface{}{"value": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Taint()
+	if err := e.Eval(&countingEvaluator{}, map[string]interface{}{"value": "plain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.IsTainted() {
+		t.Error("the taint of the previous result was kept")
+	}
+}
+
+type references [][]string
+
+func (r references) References() [][]string {
+	return r
+}
+
+func TestTainted(t *testing.T) {
+	secrets := map[string]string{"TOKEN": "s3cr3t", "EMPTY": ""}
+	tests := []struct {
+		name  string
+		refs  references
+		value interface{}
+		want  bool
+	}{
+		{"reads a secret", references{{"secrets", "TOKEN"}}, "x", true},
+		{"reads the secrets context", references{{"Secrets"}}, nil, true},
+		{"contains a secret", references{{"env", "A"}}, "token=s3cr3t", true},
+		{"contains a secret in a list", nil, []interface{}{"a", "s3cr3t"}, true},
+		{"clean", references{{"env", "A"}}, "token=public", false},
+		{"empty secrets never match", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &AnyExpression{}
+			e.SetRaw("raw", tt.refs)
+
+			if got := Tainted(e, tt.value, secrets); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
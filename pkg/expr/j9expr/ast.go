@@ -0,0 +1,88 @@
+package j9expr
+
+type Node interface {
+	Pos() int
+}
+
+type LiteralNode struct {
+	Offset int
+	Value  interface{}
+}
+
+type IdentNode struct {
+	Offset int
+	Name   string
+}
+
+type PropertyNode struct {
+	Offset int
+	Target Node
+	Name   string
+}
+
+type IndexNode struct {
+	Offset int
+	Target Node
+	Index  Node
+}
+
+type CallNode struct {
+	Offset int
+	Name   string
+	Args   []Node
+}
+
+type NotNode struct {
+	Offset  int
+	Operand Node
+}
+
+type NegateNode struct {
+	Offset  int
+	Operand Node
+}
+
+type ConditionalNode struct {
+	Offset int
+	Cond   Node
+	Then   Node
+	Else   Node
+}
+
+type BinaryNode struct {
+	Offset   int
+	Operator string
+	Left     Node
+	Right    Node
+}
+
+func (n *LiteralNode) Pos() int     { return n.Offset }
+func (n *IdentNode) Pos() int       { return n.Offset }
+func (n *PropertyNode) Pos() int    { return n.Offset }
+func (n *IndexNode) Pos() int       { return n.Offset }
+func (n *CallNode) Pos() int        { return n.Offset }
+func (n *NotNode) Pos() int         { return n.Offset }
+func (n *NegateNode) Pos() int      { return n.Offset }
+func (n *ConditionalNode) Pos() int { return n.Offset }
+func (n *BinaryNode) Pos() int      { return n.Offset }
+
+// Segment is either literal text or a parsed `${{ }}` expression within a
+// template. Exactly one of Text or Expr is meaningful.
+type Segment struct {
+	Text   string
+	Expr   Node
+	Offset int
+}
+
+// Template is a compiled string that may contain any number of `${{ }}`
+// expressions.
+type Template struct {
+	Source   string
+	Segments []Segment
+}
+
+// IsSingle reports whether the template consists of exactly one expression
+// and nothing else, in which case its result keeps its native type.
+func (t *Template) IsSingle() bool {
+	return len(t.Segments) == 1 && t.Segments[0].Expr != nil
+}
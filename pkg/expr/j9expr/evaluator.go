@@ -0,0 +1,161 @@
+package j9expr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+)
+
+// Evaluator implements expr.Evaluator and expr.ValueEvaluator for `${{ }}`
+// expressions.
+type Evaluator struct {
+	functions *Functions
+	syntax    Syntax
+	limits    expr.Limits
+	signal    context.Context
+}
+
+func init() {
+	expr.RegisterDialect(expr.DefaultDialect, func() expr.Dialect {
+		return New()
+	})
+}
+
+// New creates an evaluator with the core function library registered.
+func New() *Evaluator {
+	return NewSyntax(DefaultSyntax)
+}
+
+// NewSyntax creates an evaluator for an alternative syntax that shares the
+// interpreter and core function library.
+func NewSyntax(syntax Syntax) *Evaluator {
+	functions := NewFunctions()
+	registerCoreFunctions(functions)
+	registerFsFunctions(functions)
+	registerStatusFunctions(functions)
+	return &Evaluator{functions: functions, syntax: syntax, limits: expr.DefaultLimits}
+}
+
+func (e *Evaluator) Limits() expr.Limits {
+	return e.limits
+}
+
+func (e *Evaluator) SetLimits(limits expr.Limits) *Evaluator {
+	e.limits = limits
+	return e
+}
+
+// WithSignal returns a copy of the evaluator, sharing its function library,
+// whose evaluations fail once signal is done.
+func (e *Evaluator) WithSignal(signal context.Context) expr.Evaluator {
+	c := *e
+	c.signal = signal
+	return &c
+}
+
+func (e *Evaluator) Syntax() Syntax {
+	return e.syntax
+}
+
+func (e *Evaluator) Functions() *Functions {
+	return e.functions
+}
+
+// Register adds a Go function to the evaluator's function library.
+func (e *Evaluator) Register(name string, fn Function) *Evaluator {
+	e.functions.Register(name, fn)
+	return e
+}
+
+// IsExpression reports whether the value contains at least one `${{` opener.
+func IsExpression(value string) bool {
+	return strings.Contains(value, DefaultSyntax.Open)
+}
+
+// IsExpression reports whether the value contains the evaluator's opening
+// delimiter.
+func (e *Evaluator) IsExpression(value string) bool {
+	return strings.Contains(value, e.syntax.Open)
+}
+
+func (e *Evaluator) Compile(template string) (interface{}, error) {
+	tpl, err := ParseSyntax(template, e.syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	return tpl, nil
+}
+
+func (e *Evaluator) EvalCompiled(compiled interface{}, ctx map[string]interface{}) (interface{}, error) {
+	tpl, ok := compiled.(*Template)
+	if !ok {
+		return nil, fmt.Errorf("j9expr: cannot evaluate compiled value of type %T", compiled)
+	}
+
+	return e.EvalTemplate(tpl, ctx)
+}
+
+func (e *Evaluator) Eval(template string, ctx map[string]interface{}) (string, error) {
+	value, err := e.EvalValue(template, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return expr.ToString(value), nil
+}
+
+func (e *Evaluator) EvalValue(template string, ctx map[string]interface{}) (interface{}, error) {
+	tpl, err := ParseSyntax(template, e.syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.EvalTemplate(tpl, ctx)
+}
+
+// EvalTemplate evaluates a compiled template. A template holding a single
+// expression yields the expression's native value; anything else yields the
+// interpolated string.
+func (e *Evaluator) EvalTemplate(tpl *Template, ctx map[string]interface{}) (interface{}, error) {
+	in := &interpreter{data: ctx, functions: e.functions, limits: e.limits, signal: e.signal}
+	if tpl.IsSingle() {
+		return in.evalSegment(tpl, tpl.Segments[0])
+	}
+
+	var sb strings.Builder
+	for _, segment := range tpl.Segments {
+		if segment.Expr == nil {
+			sb.WriteString(segment.Text)
+			continue
+		}
+
+		value, err := in.evalSegment(tpl, segment)
+		if err != nil {
+			return nil, err
+		}
+
+		sb.WriteString(expr.ToString(value))
+		if e.limits.MaxOutput > 0 && sb.Len() > e.limits.MaxOutput {
+			err := &expr.LimitError{Limit: "output size", Max: e.limits.MaxOutput}
+			return nil, &EvalError{Source: tpl.Source, Offset: segment.Offset, Message: err.Error(), Err: err}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func (in *interpreter) evalSegment(tpl *Template, segment Segment) (interface{}, error) {
+	value, err := in.eval(segment.Expr)
+	if err != nil {
+		if ee, ok := err.(*EvalError); ok {
+			ee.Source = tpl.Source
+		}
+
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,442 @@
+package j9expr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+)
+
+// ContextKey is the expression context entry that carries run information,
+// such as the workspace root and the task's working directory, which the
+// filesystem functions resolve paths against.
+const ContextKey = "j9"
+
+func registerFsFunctions(f *Functions) {
+	f.Register("hashFiles", fnHashFiles)
+	f.Register("glob", fnGlob)
+	f.Register("readFile", fnReadFile)
+	f.Register("fileExists", fnFileExists)
+	f.Register("dirname", fnDirname)
+	f.Register("basename", fnBasename)
+}
+
+type workspace struct {
+	root   string
+	cwd    string
+	signal context.Context
+}
+
+func workspaceOf(call *Call) (*workspace, error) {
+	var root, cwd string
+	if info, ok := Normalize(call.Data[ContextKey]).(map[string]interface{}); ok {
+		if s, ok := info["workspace"].(string); ok {
+			root = s
+		}
+
+		if s, ok := info["cwd"].(string); ok {
+			cwd = s
+		}
+	}
+
+	// without a workspace there is nothing to confine paths to.
+	if root == "" {
+		return nil, fmt.Errorf("%s.workspace is not set, so files cannot be accessed", ContextKey)
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &workspace{root: realPath(root), signal: call.Signal}
+	if cwd == "" {
+		ws.cwd = ws.root
+	} else {
+		if !filepath.IsAbs(cwd) {
+			cwd = filepath.Join(root, cwd)
+		}
+
+		ws.cwd = realPath(filepath.Clean(cwd))
+	}
+
+	if !ws.contains(ws.cwd) {
+		return nil, fmt.Errorf("working directory %s is outside of the workspace", cwd)
+	}
+
+	return ws, nil
+}
+
+// realPath resolves the symlinks in p. Where p does not exist, its deepest
+// existing parent is resolved instead and the rest appended, so that paths
+// are compared with the workspace root in the same form either way.
+func realPath(p string) string {
+	rest := ""
+	for {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(real, rest)
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(p, rest)
+		}
+
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
+
+func (w *workspace) contains(p string) bool {
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolve maps p onto an absolute path inside the workspace, refusing any
+// path that escapes the workspace root once its symlinks are resolved.
+func (w *workspace) resolve(p string) (string, error) {
+	target := filepath.FromSlash(p)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(w.cwd, target)
+	}
+
+	target = filepath.Clean(target)
+	if !w.contains(realPath(target)) {
+		return "", fmt.Errorf("path %s is outside of the workspace", p)
+	}
+
+	return target, nil
+}
+
+// match reports whether the slash separated name matches pattern. In addition
+// to path.Match syntax, a `**` segment matches zero or more directories.
+func match(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// globFiles returns the regular files under the working directory that match
+// any of the include patterns and none of the `!` exclude patterns, sorted by
+// their slash separated path relative to the working directory.
+func (w *workspace) globFiles(patterns []string) ([]string, error) {
+	includes := []string{}
+	excludes := []string{}
+	for _, p := range patterns {
+		p = filepath.ToSlash(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+
+		exclude := strings.HasPrefix(p, "!")
+		if exclude {
+			p = p[1:]
+		}
+
+		p = strings.TrimPrefix(path.Clean(p), "./")
+		if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("glob pattern %s must stay within the working directory", p)
+		}
+
+		if exclude {
+			excludes = append(excludes, p)
+		} else {
+			includes = append(includes, p)
+		}
+	}
+
+	files := []string{}
+	if len(includes) == 0 {
+		return files, nil
+	}
+
+	err := filepath.WalkDir(w.cwd, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if w.signal != nil && w.signal.Err() != nil {
+			return w.signal.Err()
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.cwd, p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		matched := false
+		for _, include := range includes {
+			if match(include, rel) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil
+		}
+
+		for _, exclude := range excludes {
+			if match(exclude, rel) {
+				return nil
+			}
+		}
+
+		// symlinks leading out of the workspace, or to directories, are
+		// left out rather than failing the whole match.
+		target, err := w.resolve(p)
+		if err != nil {
+			return nil
+		}
+
+		if info, err := os.Stat(target); err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func hashFile(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+func fnHashFiles(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, -1); err != nil {
+		return nil, err
+	}
+
+	patterns := make([]string, len(args))
+	for i := range args {
+		s, err := StringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = s
+	}
+
+	ws, err := workspaceOf(call)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ws.globFiles(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	h := sha256.New()
+	for _, file := range files {
+		if ws.signal != nil && ws.signal.Err() != nil {
+			return nil, ws.signal.Err()
+		}
+
+		sum, err := hashFile(filepath.Join(ws.cwd, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		h.Write(sum)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fnGlob(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	pattern, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := workspaceOf(call)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ws.globFiles([]string{pattern})
+	if err != nil {
+		return nil, &ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	result := make([]interface{}, len(files))
+	for i, file := range files {
+		result[i] = file
+	}
+
+	return result, nil
+}
+
+func fnReadFile(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	p, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := workspaceOf(call)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := ws.resolve(p)
+	if err != nil {
+		return nil, &ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		return nil, &ArgumentError{Index: 0, Message: err.Error()}
+	}
+	defer file.Close()
+
+	// reading one byte past MaxOutput tells a file at the limit from a larger
+	// one without reading all of it.
+	var r io.Reader = file
+	max := call.Limits.MaxOutput
+	if max > 0 {
+		r = io.LimitReader(file, int64(max)+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	if max > 0 && len(data) > max {
+		return nil, &expr.LimitError{Limit: "output size", Max: max}
+	}
+
+	return string(data), nil
+}
+
+func fnFileExists(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	p, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := workspaceOf(call)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := ws.resolve(p)
+	if err != nil {
+		return nil, &ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	_, err = os.Stat(target)
+	return err == nil, nil
+}
+
+// fnDirname and fnBasename treat their argument as a slash separated path,
+// so they give the same result on every platform.
+func fnDirname(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	p, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return path.Dir(filepath.ToSlash(p)), nil
+}
+
+func fnBasename(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	p, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return path.Base(filepath.ToSlash(p)), nil
+}
@@ -0,0 +1,141 @@
+package j9expr
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newWorkspace creates a workspace with a few files, a symlink to it and a
+// file outside of it.
+func newWorkspace(t *testing.T) (root, link, outside string) {
+	t.Helper()
+	dir := t.TempDir()
+	root = filepath.Join(dir, "ws")
+	outside = filepath.Join(dir, "secret.txt")
+	files := map[string]string{
+		filepath.Join(root, "a.txt"):              "a",
+		filepath.Join(root, "src", "b.go"):        "b",
+		filepath.Join(root, "src", "sub", "c.go"): "c",
+		outside: "secret",
+	}
+
+	for p, content := range files {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	link = filepath.Join(dir, "link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	return root, link, outside
+}
+
+func TestFsFunctions(t *testing.T) {
+	root, link, outside := newWorkspace(t)
+	if err := os.Symlink(outside, filepath.Join(root, "src", "escape.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		workspace string
+		cwd       string
+		expr      string
+		want      interface{}
+	}{
+		{"readFile", root, "", "${{ readFile('a.txt') }}", "a"},
+		{"readFile from cwd", root, "src", "${{ readFile('b.go') }}", "b"},
+		{"readFile absolute under symlinked workspace", link, "", "${{ readFile('" + filepath.ToSlash(filepath.Join(link, "a.txt")) + "') }}", "a"},
+		{"readFile absolute under resolved workspace", link, "", "${{ readFile('" + filepath.ToSlash(filepath.Join(root, "a.txt")) + "') }}", "a"},
+		{"fileExists", root, "", "${{ fileExists('src/b.go') }}", true},
+		{"fileExists missing", root, "", "${{ fileExists('src/nope.go') }}", false},
+		{"glob", root, "", "${{ glob('**/*.go') }}", []interface{}{"src/b.go", "src/sub/c.go"}},
+		{"glob with symlinked cwd", link, filepath.Join(link, "src"), "${{ glob('*.go') }}", []interface{}{"b.go"}},
+		{"glob skips escaping symlinks", root, "src", "${{ glob('*.go') }}", []interface{}{"b.go"}},
+		{"hashFiles skips escaping symlinks", root, "", "${{ hashFiles('src/*.go') == hashFiles('src/b.go') }}", true},
+		{"hashFiles without matches", root, "", "${{ hashFiles('*.none') }}", ""},
+		{"dirname", root, "", "${{ dirname('a/b/c.txt') }}", "a/b"},
+		{"basename", root, "", "${{ basename('a/b/c.txt') }}", "c.txt"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{ContextKey: map[string]interface{}{"workspace": tt.workspace, "cwd": tt.cwd}}
+			got, err := e.EvalValue(tt.expr, data)
+			if err != nil {
+				t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalValue(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFsFunctionsStayInWorkspace(t *testing.T) {
+	root, _, outside := newWorkspace(t)
+	if err := os.Symlink(outside, filepath.Join(root, "escape.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		workspace string
+		cwd       string
+		expr      string
+		message   string
+	}{
+		{"no workspace", "", "", "${{ readFile('a.txt') }}", "workspace is not set"},
+		{"relative escape", root, "", "${{ readFile('../secret.txt') }}", "outside of the workspace"},
+		{"absolute escape", root, "", "${{ readFile('" + filepath.ToSlash(outside) + "') }}", "outside of the workspace"},
+		{"symlink escape", root, "", "${{ readFile('escape.txt') }}", "outside of the workspace"},
+		{"cwd escape", root, "..", "${{ fileExists('a.txt') }}", "outside of the workspace"},
+		{"glob escape", root, "", "${{ glob('../*') }}", "within the working directory"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{ContextKey: map[string]interface{}{"workspace": tt.workspace, "cwd": tt.cwd}}
+			_, err := e.EvalValue(tt.expr, data)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("EvalValue(%q) error = %v, want one containing %q", tt.expr, err, tt.message)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "a.go", true},
+		{"*.go", "src/a.go", false},
+		{"**/*.go", "a.go", true},
+		{"**/*.go", "src/sub/a.go", true},
+		{"src/**", "src/sub/a.go", true},
+		{"src/**/a.go", "src/a.go", true},
+		{"src/**/a.go", "lib/a.go", false},
+		{"src/?.go", "src/a.go", true},
+	}
+
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package j9expr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"gopkg.in/yaml.v3"
+)
+
+// Function is a Go function callable from an expression. Arguments arrive
+// already evaluated and normalized to nil, bool, int64, float64, string,
+// []interface{} or map[string]interface{}.
+type Function func(call *Call, args []interface{}) (interface{}, error)
+
+// Call describes the invocation a Function is servicing. Signal is nil unless
+// the evaluator was bound to one with WithSignal; long running functions
+// should stop once it is done. Limits are those of the evaluation, so that
+// functions reading external data can stop at MaxOutput.
+type Call struct {
+	Name   string
+	Data   map[string]interface{}
+	Signal context.Context
+	Limits expr.Limits
+}
+
+// ArgumentError is returned by functions to report a bad argument. Index is
+// zero-based.
+type ArgumentError struct {
+	Index   int
+	Message string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("argument %d: %s", e.Index+1, e.Message)
+}
+
+type FunctionError struct {
+	Name string
+	Err  error
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("%s(): %s", e.Name, e.Err.Error())
+}
+
+func (e *FunctionError) Unwrap() error {
+	return e.Err
+}
+
+type Functions struct {
+	funcs map[string]Function
+	names map[string]string
+}
+
+func NewFunctions() *Functions {
+	return &Functions{funcs: make(map[string]Function), names: make(map[string]string)}
+}
+
+// Register adds or replaces a function. Names are case-insensitive.
+func (f *Functions) Register(name string, fn Function) {
+	f.funcs[strings.ToLower(name)] = fn
+	f.names[strings.ToLower(name)] = name
+}
+
+func (f *Functions) Get(name string) (Function, bool) {
+	fn, ok := f.funcs[strings.ToLower(name)]
+	return fn, ok
+}
+
+func (f *Functions) Has(name string) bool {
+	_, ok := f.funcs[strings.ToLower(name)]
+	return ok
+}
+
+func ArgCount(args []interface{}, min, max int) error {
+	if len(args) < min {
+		return fmt.Errorf("expected at least %d arguments but got %d", min, len(args))
+	}
+
+	if max >= 0 && len(args) > max {
+		return fmt.Errorf("expected at most %d arguments but got %d", max, len(args))
+	}
+
+	return nil
+}
+
+func StringArg(args []interface{}, index int) (string, error) {
+	switch v := args[index].(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	case bool, int64, float64:
+		return expr.ToString(v), nil
+	}
+
+	return "", &ArgumentError{Index: index, Message: "expected string but got " + expr.TypeName(args[index])}
+}
+
+func registerCoreFunctions(f *Functions) {
+	f.Register("contains", fnContains)
+	f.Register("startsWith", fnStartsWith)
+	f.Register("endsWith", fnEndsWith)
+	f.Register("format", fnFormat)
+	f.Register("join", fnJoin)
+	f.Register("split", fnSplit)
+	f.Register("toJSON", fnToJSON)
+	f.Register("fromJSON", fnFromJSON)
+	f.Register("fromYAML", fnFromYAML)
+	f.Register("replace", fnReplace)
+	f.Register("lower", fnLower)
+	f.Register("upper", fnUpper)
+	f.Register("trim", fnTrim)
+	f.Register("coalesce", fnCoalesce)
+}
+
+func fnContains(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	if list, ok := args[0].([]interface{}); ok {
+		for _, item := range list {
+			if equal(Normalize(item), args[1]) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	search, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := StringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Contains(strings.ToLower(search), strings.ToLower(item)), nil
+}
+
+func fnStartsWith(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	search, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := StringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasPrefix(strings.ToLower(search), strings.ToLower(prefix)), nil
+}
+
+func fnEndsWith(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	search, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix, err := StringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasSuffix(strings.ToLower(search), strings.ToLower(suffix)), nil
+}
+
+func fnFormat(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, -1); err != nil {
+		return nil, err
+	}
+
+	format, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '{' && i+1 < len(format) && format[i+1] == '{':
+			sb.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(format) && format[i+1] == '}':
+			sb.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, &ArgumentError{Index: 0, Message: "unclosed placeholder in format string"}
+			}
+
+			n, err := strconv.Atoi(format[i+1 : i+end])
+			if err != nil || n < 0 {
+				return nil, &ArgumentError{Index: 0, Message: fmt.Sprintf("invalid placeholder %s", format[i:i+end+1])}
+			}
+
+			if n+1 >= len(args) {
+				return nil, &ArgumentError{Index: 0, Message: fmt.Sprintf("placeholder {%d} has no matching argument", n)}
+			}
+
+			sb.WriteString(expr.ToString(args[n+1]))
+			i += end
+		case c == '}':
+			return nil, &ArgumentError{Index: 0, Message: "unescaped } in format string"}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func fnJoin(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	sep := ","
+	if len(args) == 2 {
+		s, err := StringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		sep = s
+	}
+
+	list, ok := args[0].([]interface{})
+	if !ok {
+		s, err := StringArg(args, 0)
+		if err != nil {
+			return nil, &ArgumentError{Index: 0, Message: "expected array but got " + expr.TypeName(args[0])}
+		}
+		return s, nil
+	}
+
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = expr.ToString(Normalize(item))
+	}
+
+	return strings.Join(parts, sep), nil
+}
+
+func fnSplit(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	s, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := ","
+	if len(args) == 2 {
+		sep, err = StringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := []interface{}{}
+	if s == "" {
+		return result, nil
+	}
+
+	for _, part := range strings.Split(s, sep) {
+		result = append(result, part)
+	}
+
+	return result, nil
+}
+
+func fnToJSON(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(args[0], "", "  ")
+	if err != nil {
+		return nil, &ArgumentError{Index: 0, Message: err.Error()}
+	}
+
+	return string(data), nil
+}
+
+func fnFromJSON(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	s, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, &ArgumentError{Index: 0, Message: "invalid JSON: " + err.Error()}
+	}
+
+	return value, nil
+}
+
+func fnFromYAML(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	s, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return nil, &ArgumentError{Index: 0, Message: "invalid YAML: " + err.Error()}
+	}
+
+	return normalizeYAML(value), nil
+}
+
+// normalizeYAML converts decoded YAML into the plain maps, slices and
+// scalars the interpreter works with, at every level. Keys that are not
+// strings, such as `1: one` or `true: yes`, become their string form.
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = normalizeYAML(item)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[expr.ToString(Normalize(key))] = normalizeYAML(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalizeYAML(item)
+		}
+		return result
+	}
+
+	return Normalize(value)
+}
+
+func fnReplace(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 3, 3); err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, 3)
+	for i := range strs {
+		s, err := StringArg(args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+
+	return strings.ReplaceAll(strs[0], strs[1], strs[2]), nil
+}
+
+func fnLower(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	s, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.ToLower(s), nil
+}
+
+func fnUpper(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	s, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.ToUpper(s), nil
+}
+
+func fnTrim(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	s, err := StringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 2 {
+		cutset, err := StringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.Trim(s, cutset), nil
+	}
+
+	return strings.TrimSpace(s), nil
+}
+
+func fnCoalesce(call *Call, args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg == nil {
+			continue
+		}
+
+		if s, ok := arg.(string); ok && s == "" {
+			continue
+		}
+
+		return arg, nil
+	}
+
+	return nil, nil
+}
+
+// Names lists the registered functions by the name they were registered with.
+func (f *Functions) Names() []string {
+	names := make([]string, 0, len(f.names))
+	for _, name := range f.names {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package j9expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoreFunctions(t *testing.T) {
+	data := map[string]interface{}{
+		"list": []interface{}{"a", "B", int64(3)},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		{"contains string", "${{ contains('Hello', 'ELL') }}", true},
+		{"contains list", "${{ contains(list, 'b') }}", true},
+		{"contains list number", "${{ contains(list, 3) }}", true},
+		{"contains list missing", "${{ contains(list, 'c') }}", false},
+		{"startsWith", "${{ startsWith('Hello', 'he') }}", true},
+		{"endsWith", "${{ endsWith('Hello', 'LO') }}", true},
+		{"format", "${{ format('{0}-{1}', 'a', 2) }}", "a-2"},
+		{"format braces", "${{ format('{{0}} {0}', 'x') }}", "{0} x"},
+		{"join", "${{ join(list) }}", "a,B,3"},
+		{"join separator", "${{ join(list, ' ') }}", "a B 3"},
+		{"split", "${{ split('a,b', ',') }}", []interface{}{"a", "b"}},
+		{"split empty", "${{ split('') }}", []interface{}{}},
+		{"toJSON", "${{ toJSON(split('a', ',')) }}", "[\n  \"a\"\n]"},
+		{"fromJSON", "${{ fromJSON('{\"a\": [1]}').a[0] }}", float64(1)},
+		{"fromYAML", "${{ fromYAML('a: 1').a }}", int64(1)},
+		{"fromYAML nested", "${{ fromYAML('a:\n  b:\n    - c: 2').a.b[0].c }}", int64(2)},
+		{"fromYAML non-string keys", "${{ fromYAML('a:\n  1: one\n  true: yes')['a']['1'] }}", "one"},
+		{"fromYAML non-string keys to JSON", "${{ toJSON(fromYAML('1: [2]')) }}", "{\n  \"1\": [\n    2\n  ]\n}"},
+		{"replace", "${{ replace('a-b-c', '-', '+') }}", "a+b+c"},
+		{"lower", "${{ lower('ABC') }}", "abc"},
+		{"upper", "${{ upper('abc') }}", "ABC"},
+		{"trim", "${{ trim('  x  ') }}", "x"},
+		{"coalesce", "${{ coalesce(null, '', 'x', 'y') }}", "x"},
+		{"names ignore case", "${{ TOJSON(1) }}", "1"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.EvalValue(tt.expr, data)
+			if err != nil {
+				t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalValue(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoreFunctionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"too few arguments", "${{ contains('a') }}"},
+		{"too many arguments", "${{ lower('a', 'b') }}"},
+		{"missing placeholder argument", "${{ format('{1}', 'a') }}"},
+		{"unclosed placeholder", "${{ format('{0', 'a') }}"},
+		{"invalid JSON", "${{ fromJSON('{') }}"},
+		{"invalid YAML", "${{ fromYAML('a: [') }}"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := e.EvalValue(tt.expr, map[string]interface{}{}); err == nil {
+				t.Errorf("EvalValue(%q) = %#v, want an error", tt.expr, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,583 @@
+package j9expr
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+type EvalError struct {
+	Source  string
+	Offset  int
+	Message string
+	Err     error
+}
+
+func (e *EvalError) Position() (int, int) {
+	return expr.Position(e.Source, e.Offset)
+}
+
+func (e *EvalError) Error() string {
+	line, column := e.Position()
+	return fmt.Sprintf("expression error: %s on line %d at column %d", e.Message, line, column)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+type interpreter struct {
+	data      map[string]interface{}
+	functions *Functions
+	limits    expr.Limits
+	signal    context.Context
+	steps     int
+	depth     int
+}
+
+func (in *interpreter) eval(node Node) (interface{}, error) {
+	in.steps++
+	if in.limits.MaxSteps > 0 && in.steps > in.limits.MaxSteps {
+		err := &expr.LimitError{Limit: "steps", Max: in.limits.MaxSteps}
+		return nil, &EvalError{Offset: node.Pos(), Message: err.Error(), Err: err}
+	}
+
+	if in.signal != nil {
+		if err := in.signal.Err(); err != nil {
+			return nil, &EvalError{Offset: node.Pos(), Message: "evaluation cancelled", Err: err}
+		}
+	}
+
+	in.depth++
+	defer func() { in.depth-- }()
+	if in.limits.MaxDepth > 0 && in.depth > in.limits.MaxDepth {
+		err := &expr.LimitError{Limit: "depth", Max: in.limits.MaxDepth}
+		return nil, &EvalError{Offset: node.Pos(), Message: err.Error(), Err: err}
+	}
+
+	return in.evalNode(node)
+}
+
+// checkValue applies the output limits to values the expression creates.
+// Values read from the context are not checked.
+func (in *interpreter) checkValue(node Node, value interface{}) error {
+	if err := in.limits.CheckValue(value); err != nil {
+		return &EvalError{Offset: node.Pos(), Message: err.Error(), Err: err}
+	}
+
+	return nil
+}
+
+func (in *interpreter) evalNode(node Node) (interface{}, error) {
+	switch n := node.(type) {
+	case *LiteralNode:
+		return n.Value, nil
+
+	case *IdentNode:
+		if in.data == nil {
+			return nil, nil
+		}
+
+		return Normalize(in.data[n.Name]), nil
+
+	case *PropertyNode:
+		target, err := in.eval(n.Target)
+		if err != nil {
+			return nil, err
+		}
+
+		return property(target, n.Name), nil
+
+	case *IndexNode:
+		target, err := in.eval(n.Target)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := in.eval(n.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		return indexValue(target, index), nil
+
+	case *CallNode:
+		var fn Function
+		if in.functions != nil {
+			fn, _ = in.functions.Get(n.Name)
+		}
+
+		if fn == nil {
+			return nil, &EvalError{Offset: n.Offset, Message: "unknown function " + n.Name}
+		}
+
+		args := make([]interface{}, len(n.Args))
+		for i, arg := range n.Args {
+			value, err := in.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			args[i] = value
+		}
+
+		result, err := fn(&Call{Name: n.Name, Data: in.data, Signal: in.signal, Limits: in.limits}, args)
+		if err != nil {
+			ferr := &FunctionError{Name: n.Name, Err: err}
+			return nil, &EvalError{Offset: n.Offset, Message: ferr.Error(), Err: ferr}
+		}
+
+		result = Normalize(result)
+		if err := in.checkValue(n, result); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+
+	case *NotNode:
+		operand, err := in.eval(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+
+		return !Truthy(operand), nil
+
+	case *NegateNode:
+		operand, err := in.eval(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := operand.(type) {
+		case int64:
+			return -v, nil
+		case float64:
+			return -v, nil
+		}
+
+		return nil, &EvalError{Offset: n.Offset, Message: "cannot negate " + expr.TypeName(operand)}
+
+	case *ConditionalNode:
+		cond, err := in.eval(n.Cond)
+		if err != nil {
+			return nil, err
+		}
+
+		if Truthy(cond) {
+			return in.eval(n.Then)
+		}
+		return in.eval(n.Else)
+
+	case *BinaryNode:
+		left, err := in.eval(n.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		switch n.Operator {
+		case "&&":
+			if !Truthy(left) {
+				return left, nil
+			}
+			return in.eval(n.Right)
+		case "||":
+			if Truthy(left) {
+				return left, nil
+			}
+			return in.eval(n.Right)
+		}
+
+		right, err := in.eval(n.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch n.Operator {
+		case "==":
+			return equal(left, right), nil
+		case "!=":
+			return !equal(left, right), nil
+		case "<", "<=", ">", ">=":
+			return compare(n.Operator, left, right), nil
+		case "in":
+			return member(left, right), nil
+		case "+", "-", "*", "/", "%":
+			result, err := arithmetic(n.Operator, left, right)
+			if err != nil {
+				return nil, &EvalError{Offset: n.Offset, Message: err.Error(), Err: err}
+			}
+
+			if err := in.checkValue(n, result); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+
+		return nil, &EvalError{Offset: n.Offset, Message: "unknown operator " + n.Operator}
+	}
+
+	return nil, &EvalError{Offset: node.Pos(), Message: fmt.Sprintf("unsupported node %T", node)}
+}
+
+// Normalize converts well known container types into the plain maps and
+// slices the interpreter works with, leaving scalars untouched.
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, int64, float64, []interface{}, map[string]interface{}:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case map[string]string:
+		result := make(map[string]interface{}, len(v))
+		for key, s := range v {
+			result[key] = s
+		}
+		return result
+	case []string:
+		result := make([]interface{}, len(v))
+		for i, s := range v {
+			result[i] = s
+		}
+		return result
+	case *primitives.ObjectMap:
+		if v == nil {
+			return nil
+		}
+		return v.Items
+	case primitives.ObjectMap:
+		return v.Items
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value
+		}
+
+		result := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = iter.Value().Interface()
+		}
+		return result
+
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			result[i] = rv.Index(i).Interface()
+		}
+		return result
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+
+	return value
+}
+
+func property(target interface{}, name string) interface{} {
+	if m, ok := target.(map[string]interface{}); ok {
+		if v, ok := m[name]; ok {
+			return Normalize(v)
+		}
+
+		// property names are matched case-insensitively as a fallback
+		for key, v := range m {
+			if strings.EqualFold(key, name) {
+				return Normalize(v)
+			}
+		}
+	}
+
+	return nil
+}
+
+func indexValue(target interface{}, index interface{}) interface{} {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		if s, ok := index.(string); ok {
+			return property(t, s)
+		}
+
+		return property(t, expr.ToString(index))
+
+	case []interface{}:
+		f, ok := toNumber(index)
+		if !ok || f != math.Trunc(f) || f < 0 || int(f) >= len(t) {
+			return nil
+		}
+
+		return Normalize(t[int(f)])
+	}
+
+	return nil
+}
+
+func member(item, container interface{}) bool {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, v := range c {
+			if equal(item, Normalize(v)) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		if key, ok := item.(string); ok {
+			_, found := c[key]
+			return found
+		}
+	case string:
+		if s, ok := item.(string); ok {
+			return strings.Contains(c, s)
+		}
+	}
+
+	return false
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	if op == "+" {
+		if l, ok := left.(string); ok {
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		}
+
+		if l, ok := left.([]interface{}); ok {
+			if r, ok := right.([]interface{}); ok {
+				result := make([]interface{}, 0, len(l)+len(r))
+				return append(append(result, l...), r...), nil
+			}
+		}
+	}
+
+	if !isNumber(left) || !isNumber(right) {
+		return nil, fmt.Errorf("operator %s is not defined for %s and %s", op, expr.TypeName(left), expr.TypeName(right))
+	}
+
+	li, lint := left.(int64)
+	ri, rint := right.(int64)
+	if lint && rint {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return li / ri, nil
+		default:
+			if ri == 0 {
+				return nil, fmt.Errorf("modulus by zero")
+			}
+			return li % ri, nil
+		}
+	}
+
+	l, _ := toNumber(left)
+	r, _ := toNumber(right)
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	default:
+		if r == 0 {
+			return nil, fmt.Errorf("modulus by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+}
+
+// Truthy follows the usual workflow semantics: null, false, 0, NaN and the
+// empty string are falsy, everything else is truthy.
+func Truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	}
+
+	return true
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case nil:
+		return 0, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0, true
+		}
+
+		if n, err := parseNumber(s); err == nil {
+			switch n := n.(type) {
+			case int64:
+				return float64(n), true
+			case float64:
+				return n, true
+			}
+		}
+
+		return math.NaN(), false
+	}
+
+	return math.NaN(), false
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64:
+		return true
+	}
+
+	return false
+}
+
+// equal compares maps and slices by their contents, applying the same loose
+// rules to their elements as to scalars.
+func equal(left, right interface{}) bool {
+	switch l := left.(type) {
+	case nil:
+		if right == nil {
+			return true
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return strings.EqualFold(l, r)
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			return l == r
+		}
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+
+		for key, v := range l {
+			rv, ok := r[key]
+			if !ok || !equal(Normalize(v), Normalize(rv)) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+
+		for i := range l {
+			if !equal(Normalize(l[i]), Normalize(r[i])) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if isNumber(left) && isNumber(right) {
+		l, _ := toNumber(left)
+		r, _ := toNumber(right)
+		return l == r
+	}
+
+	switch right.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return false
+	}
+
+	return l == r
+}
+
+func compare(op string, left, right interface{}) bool {
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			c := strings.Compare(strings.ToLower(l), strings.ToLower(r))
+			switch op {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			default:
+				return c >= 0
+			}
+		}
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return false
+	}
+
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
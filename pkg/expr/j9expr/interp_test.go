@@ -0,0 +1,96 @@
+package j9expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvalValue(t *testing.T) {
+	data := map[string]interface{}{
+		"env": map[string]string{"NAME": "world", "EMPTY": ""},
+		"vars": map[string]interface{}{
+			"count": 3,
+			"list":  []interface{}{"a", "b"},
+			"nested": map[string]interface{}{
+				"items": []interface{}{map[string]interface{}{"id": 1}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		{"string literal", "${{ 'hi' }}", "hi"},
+		{"escaped quote", "${{ 'it''s' }}", "it's"},
+		{"integer", "${{ 42 }}", int64(42)},
+		{"float", "${{ 1.5 }}", 1.5},
+		{"hex", "${{ 0xff }}", int64(255)},
+		{"null", "${{ null }}", nil},
+		{"property", "${{ env.NAME }}", "world"},
+		{"property ignores case", "${{ env.name }}", "world"},
+		{"index", "${{ vars.list[1] }}", "b"},
+		{"string index", "${{ vars['count'] }}", int64(3)},
+		{"nested", "${{ vars.nested.items[0].id }}", int64(1)},
+		{"missing property", "${{ vars.missing }}", nil},
+		{"unknown identifier", "${{ nope }}", nil},
+		{"interpolation", "hello ${{ env.NAME }}!", "hello world!"},
+		{"interpolated null", "a${{ null }}b", "ab"},
+		{"escaped opener", "echo $${{ env.NAME }}", "echo ${{ env.NAME }}"},
+		{"escaped opener next to an expression", "$${{ x }} ${{ env.NAME }}", "${{ x }} world"},
+		{"not", "${{ !env.EMPTY }}", true},
+		{"and yields operand", "${{ env.NAME && 'yes' }}", "yes"},
+		{"or yields operand", "${{ env.EMPTY || 'fallback' }}", "fallback"},
+		{"string equality ignores case", "${{ 'ABC' == 'abc' }}", true},
+		{"number coercion", "${{ '3' == 3 }}", true},
+		{"null equals zero", "${{ null == 0 }}", true},
+		{"comparison", "${{ vars.count > 2 }}", true},
+		{"string comparison", "${{ 'a' < 'B' }}", true},
+		{"NaN is not equal", "${{ 'abc' == 0 }}", false},
+		{"precedence", "${{ true || false && false }}", true},
+		{"grouping", "${{ (true || false) && false }}", false},
+		{"map equals itself", "${{ env == env }}", true},
+		{"equal lists", "${{ fromJSON('[1, \"a\"]') == fromJSON('[1, \"A\"]') }}", true},
+		{"different lists", "${{ fromJSON('[1, 2]') == fromJSON('[1, 3]') }}", false},
+		{"equal maps", "${{ fromJSON('{\"a\": {\"b\": 1}}') == fromJSON('{\"a\": {\"b\": 1}}') }}", true},
+		{"different maps", "${{ fromJSON('{\"a\": 1}') == fromJSON('{\"a\": 1, \"b\": 2}') }}", false},
+		{"map and list differ", "${{ fromJSON('{}') != fromJSON('[]') }}", true},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.EvalValue(tt.expr, data)
+			if err != nil {
+				t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalValue(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalValueErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"unknown function", "${{ nope() }}"},
+		{"arithmetic is not supported", "${{ 1 + 2 }}"},
+		{"unterminated expression", "${{ true"},
+		{"unterminated string", "${{ 'abc }}"},
+		{"empty expression", "${{ }}"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := e.EvalValue(tt.expr, map[string]interface{}{}); err == nil {
+				t.Errorf("EvalValue(%q) = %#v, want an error", tt.expr, got)
+			}
+		})
+	}
+}
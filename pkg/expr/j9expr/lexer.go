@@ -0,0 +1,327 @@
+package j9expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokEnd
+	tokIdent
+	tokNumber
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokDot
+	tokComma
+	tokNot
+	tokEq
+	tokNe
+	tokLt
+	tokLe
+	tokGt
+	tokGe
+	tokAnd
+	tokOr
+	tokPlus
+	tokMinus
+	tokStar
+	tokSlash
+	tokPercent
+	tokQuestion
+	tokColon
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of input"
+	case tokEnd:
+		return "}}"
+	case tokIdent:
+		return "identifier"
+	case tokNumber:
+		return "number"
+	case tokString:
+		return "string"
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	case tokLBracket:
+		return "["
+	case tokRBracket:
+		return "]"
+	case tokDot:
+		return "."
+	case tokComma:
+		return ","
+	case tokNot:
+		return "!"
+	case tokEq:
+		return "=="
+	case tokNe:
+		return "!="
+	case tokLt:
+		return "<"
+	case tokLe:
+		return "<="
+	case tokGt:
+		return ">"
+	case tokGe:
+		return ">="
+	case tokAnd:
+		return "&&"
+	case tokOr:
+		return "||"
+	case tokPlus:
+		return "+"
+	case tokMinus:
+		return "-"
+	case tokStar:
+		return "*"
+	case tokSlash:
+		return "/"
+	case tokPercent:
+		return "%"
+	case tokQuestion:
+		return "?"
+	case tokColon:
+		return ":"
+	default:
+		return "unknown"
+	}
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type lexer struct {
+	src    string
+	pos    int
+	syntax Syntax
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (l *lexer) isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || (c == '-' && !l.syntax.CEL)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			break
+		}
+		l.pos++
+	}
+
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	if l.syntax.Close != "" && strings.HasPrefix(l.src[l.pos:], l.syntax.Close) {
+		l.pos += len(l.syntax.Close)
+		return token{kind: tokEnd, value: l.syntax.Close, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	two := ""
+	if l.pos+1 < len(l.src) {
+		two = l.src[l.pos : l.pos+2]
+	}
+
+	switch two {
+	case "==":
+		l.pos += 2
+		return token{kind: tokEq, value: two, pos: start}, nil
+	case "!=":
+		l.pos += 2
+		return token{kind: tokNe, value: two, pos: start}, nil
+	case "<=":
+		l.pos += 2
+		return token{kind: tokLe, value: two, pos: start}, nil
+	case ">=":
+		l.pos += 2
+		return token{kind: tokGe, value: two, pos: start}, nil
+	case "&&":
+		l.pos += 2
+		return token{kind: tokAnd, value: two, pos: start}, nil
+	case "||":
+		l.pos += 2
+		return token{kind: tokOr, value: two, pos: start}, nil
+	}
+
+	switch c {
+	case '(':
+		l.pos++
+		return token{kind: tokLParen, value: "(", pos: start}, nil
+	case ')':
+		l.pos++
+		return token{kind: tokRParen, value: ")", pos: start}, nil
+	case '[':
+		l.pos++
+		return token{kind: tokLBracket, value: "[", pos: start}, nil
+	case ']':
+		l.pos++
+		return token{kind: tokRBracket, value: "]", pos: start}, nil
+	case '.':
+		if l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1]) {
+			return l.number()
+		}
+		l.pos++
+		return token{kind: tokDot, value: ".", pos: start}, nil
+	case ',':
+		l.pos++
+		return token{kind: tokComma, value: ",", pos: start}, nil
+	case '!':
+		l.pos++
+		return token{kind: tokNot, value: "!", pos: start}, nil
+	case '<':
+		l.pos++
+		return token{kind: tokLt, value: "<", pos: start}, nil
+	case '>':
+		l.pos++
+		return token{kind: tokGt, value: ">", pos: start}, nil
+	case '\'', '"':
+		return l.string(c)
+	}
+
+	if l.syntax.CEL {
+		kind := tokEOF
+		switch c {
+		case '+':
+			kind = tokPlus
+		case '-':
+			kind = tokMinus
+		case '*':
+			kind = tokStar
+		case '/':
+			kind = tokSlash
+		case '%':
+			kind = tokPercent
+		case '?':
+			kind = tokQuestion
+		case ':':
+			kind = tokColon
+		}
+
+		if kind != tokEOF {
+			l.pos++
+			return token{kind: kind, value: string(c), pos: start}, nil
+		}
+	}
+
+	if isDigit(c) || (c == '-' && l.pos+1 < len(l.src) && (isDigit(l.src[l.pos+1]) || l.src[l.pos+1] == '.')) {
+		return l.number()
+	}
+
+	if isIdentStart(c) {
+		for l.pos < len(l.src) && l.isIdentPart(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, value: l.src[start:l.pos], pos: start}, nil
+	}
+
+	return token{}, &expr.SyntaxError{Offset: start, Message: fmt.Sprintf("unexpected character %q", c)}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		l.pos += 2
+		for l.pos < len(l.src) && strings.IndexByte("0123456789abcdefABCDEF", l.src[l.pos]) >= 0 {
+			l.pos++
+		}
+		return token{kind: tokNumber, value: l.src[start:l.pos], pos: start}, nil
+	}
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if isDigit(c) || c == '.' {
+			l.pos++
+			continue
+		}
+
+		if (c == 'e' || c == 'E') && l.pos+1 < len(l.src) {
+			l.pos++
+			if l.src[l.pos] == '+' || l.src[l.pos] == '-' {
+				l.pos++
+			}
+			continue
+		}
+
+		break
+	}
+
+	if l.pos < len(l.src) && isIdentStart(l.src[l.pos]) {
+		return token{}, &expr.SyntaxError{Offset: start, Message: "invalid number " + l.src[start:l.pos+1]}
+	}
+
+	return token{kind: tokNumber, value: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '\\' && l.syntax.CEL && l.pos+1 < len(l.src) {
+			l.pos++
+			switch e := l.src[l.pos]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '\\', '\'', '"':
+				sb.WriteByte(e)
+			default:
+				return token{}, &expr.SyntaxError{Offset: l.pos - 1, Message: fmt.Sprintf("invalid escape sequence \\%c", e)}
+			}
+			l.pos++
+			continue
+		}
+
+		if c == quote {
+			if !l.syntax.CEL && l.pos+1 < len(l.src) && l.src[l.pos+1] == quote {
+				sb.WriteByte(quote)
+				l.pos += 2
+				continue
+			}
+
+			l.pos++
+			return token{kind: tokString, value: sb.String(), pos: start}, nil
+		}
+
+		sb.WriteByte(c)
+		l.pos++
+	}
+
+	return token{}, &expr.SyntaxError{Offset: start, Message: "unterminated string"}
+}
@@ -0,0 +1,56 @@
+package j9expr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+)
+
+func TestLimits(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ten.txt"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		limits expr.Limits
+		expr   string
+		limit  string
+	}{
+		{"steps", expr.Limits{MaxSteps: 5}, "${{ 1 == 1 && 2 == 2 && 3 == 3 }}", "steps"},
+		{"steps within limit", expr.Limits{MaxSteps: 3}, "${{ 1 == 1 }}", ""},
+		{"expression depth", expr.Limits{MaxDepth: 3}, "${{ !!!!true }}", "depth"},
+		{"value depth", expr.Limits{MaxDepth: 3}, "${{ fromJSON('[[[[1]]]]') }}", "depth"},
+		{"depth within limit", expr.Limits{MaxDepth: 3}, "${{ fromJSON('[[1]]') }}", ""},
+		{"string output", expr.Limits{MaxOutput: 4}, "${{ join(fromJSON('[\"abc\", \"def\"]'), '') }}", "output size"},
+		{"array output", expr.Limits{MaxOutput: 2}, "${{ split('a,b,c', ',') }}", "output size"},
+		{"interpolated output", expr.Limits{MaxOutput: 4}, "${{ 'abc' }}${{ 'def' }}", "output size"},
+		{"output within limit", expr.Limits{MaxOutput: 6}, "${{ 'abc' }}${{ 'def' }}", ""},
+		{"readFile over limit", expr.Limits{MaxOutput: 4}, "${{ readFile('ten.txt') }}", "output size"},
+		{"readFile at limit", expr.Limits{MaxOutput: 10}, "${{ readFile('ten.txt') }}", ""},
+		{"no limits", expr.Limits{}, "${{ readFile('ten.txt') }}", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New().SetLimits(tt.limits)
+			data := map[string]interface{}{ContextKey: map[string]interface{}{"workspace": root}}
+			_, err := e.EvalValue(tt.expr, data)
+			if tt.limit == "" {
+				if err != nil {
+					t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+				}
+				return
+			}
+
+			var le *expr.LimitError
+			if !errors.As(err, &le) || le.Limit != tt.limit {
+				t.Errorf("EvalValue(%q) error = %v, want the %s limit", tt.expr, err, tt.limit)
+			}
+		})
+	}
+}
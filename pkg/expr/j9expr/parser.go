@@ -0,0 +1,583 @@
+package j9expr
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+)
+
+// Syntax selects the delimiters and grammar accepted by the parser.
+type Syntax struct {
+	Open  string
+	Close string
+
+	// CEL enables a CEL-style grammar: arithmetic, `in`, `? :`, method call
+	// sugar such as `name.startsWith('x')` and backslash escapes in strings.
+	// Identifiers may not contain '-' as it is the subtraction operator.
+	CEL bool
+
+	// Roots, when set, are the only names an expression may read from its
+	// context; any other name fails to compile. It keeps shell parameter
+	// expansion such as `${HOME}` from silently evaluating to null when the
+	// delimiters collide with it.
+	Roots []string
+}
+
+var DefaultSyntax = Syntax{Open: "${{", Close: "}}"}
+
+// An opening delimiter preceded by an extra `$`, such as `$${{` or, with the
+// CEL syntax, `$${HOME}`, is escaped: it is kept as text without the extra
+// `$` instead of starting an expression. This lets scripts use shell
+// parameter expansion.
+
+// Parse compiles a template containing zero or more `${{ }}` expressions.
+func Parse(source string) (*Template, error) {
+	return ParseSyntax(source, DefaultSyntax)
+}
+
+// ParseSyntax compiles a template using the delimiters and grammar of syntax.
+func ParseSyntax(source string, syntax Syntax) (*Template, error) {
+	tpl := &Template{Source: source}
+	pos := 0
+	for pos < len(source) {
+		index := strings.Index(source[pos:], syntax.Open)
+		if index < 0 {
+			tpl.Segments = append(tpl.Segments, Segment{Text: source[pos:], Offset: pos})
+			break
+		}
+
+		start := pos + index
+		if index > 0 && source[start-1] == '$' {
+			end := start + len(syntax.Open)
+			tpl.Segments = append(tpl.Segments, Segment{Text: source[pos:start-1] + syntax.Open, Offset: pos})
+			pos = end
+			continue
+		}
+
+		if index > 0 {
+			tpl.Segments = append(tpl.Segments, Segment{Text: source[pos : pos+index], Offset: pos})
+		}
+
+		p := &parser{lex: lexer{src: source, pos: start + len(syntax.Open), syntax: syntax}}
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, withSource(err, source)
+		}
+
+		if p.tok.kind != tokEnd {
+			return nil, withSource(p.unexpected(), source)
+		}
+
+		if err := checkRoots(node, syntax); err != nil {
+			return nil, withSource(err, source)
+		}
+
+		tpl.Segments = append(tpl.Segments, Segment{Expr: node, Offset: start})
+		pos = p.lex.pos
+	}
+
+	return tpl, nil
+}
+
+// ParseExpression compiles a bare expression without the `${{ }}` delimiters.
+func ParseExpression(source string) (Node, error) {
+	return ParseExpressionSyntax(source, DefaultSyntax)
+}
+
+func ParseExpressionSyntax(source string, syntax Syntax) (Node, error) {
+	syntax.Close = ""
+	p := &parser{lex: lexer{src: source, syntax: syntax}}
+	node, err := p.parseExpression()
+	if err != nil {
+		return nil, withSource(err, source)
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, withSource(p.unexpected(), source)
+	}
+
+	if err := checkRoots(node, syntax); err != nil {
+		return nil, withSource(err, source)
+	}
+
+	return node, nil
+}
+
+// checkRoots rejects names outside of syntax.Roots.
+func checkRoots(node Node, syntax Syntax) error {
+	if len(syntax.Roots) == 0 {
+		return nil
+	}
+
+	for _, ident := range collectIdents(node, nil) {
+		if !slices.Contains(syntax.Roots, ident.Name) {
+			return &expr.SyntaxError{
+				Offset:  ident.Offset,
+				Message: fmt.Sprintf("unknown name %s, expected one of %s; write $%s to keep %s as text", ident.Name, strings.Join(syntax.Roots, ", "), syntax.Open, syntax.Open),
+			}
+		}
+	}
+
+	return nil
+}
+
+func withSource(err error, source string) error {
+	if se, ok := err.(*expr.SyntaxError); ok {
+		se.Source = source
+	}
+
+	return err
+}
+
+// maxNesting bounds parser recursion so that a pathological expression
+// fails to compile instead of exhausting the stack.
+const maxNesting = 256
+
+type parser struct {
+	lex   lexer
+	tok   token
+	depth int
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return &expr.SyntaxError{Offset: p.tok.pos, Message: "unexpected end of expression"}
+	}
+
+	if p.tok.kind == tokEnd {
+		return &expr.SyntaxError{Offset: p.tok.pos, Message: "unexpected " + p.tok.value}
+	}
+
+	return &expr.SyntaxError{Offset: p.tok.pos, Message: fmt.Sprintf("unexpected %s", p.tok.kind)}
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if p.tok.kind != kind {
+		if p.tok.kind == tokEOF || p.tok.kind == tokEnd {
+			return &expr.SyntaxError{Offset: p.tok.pos, Message: fmt.Sprintf("expected %s", kind)}
+		}
+
+		return &expr.SyntaxError{Offset: p.tok.pos, Message: fmt.Sprintf("expected %s but found %s", kind, p.tok.kind)}
+	}
+
+	return p.advance()
+}
+
+func (p *parser) parseExpression() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokEnd || p.tok.kind == tokEOF {
+		return nil, &expr.SyntaxError{Offset: p.tok.pos, Message: "empty expression"}
+	}
+
+	return p.parseConditional()
+}
+
+func (p *parser) parseConditional() (Node, error) {
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokQuestion {
+		return cond, nil
+	}
+
+	pos := p.tok.pos
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(tokColon); err != nil {
+		return nil, err
+	}
+
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConditionalNode{Offset: pos, Cond: cond, Then: then, Else: otherwise}, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokOr {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryNode{Offset: op.pos, Operator: op.value, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokAnd {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryNode{Offset: op.pos, Operator: op.value, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseEquality() (Node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokEq || p.tok.kind == tokNe {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryNode{Offset: op.pos, Operator: op.value, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) isComparison() bool {
+	switch p.tok.kind {
+	case tokLt, tokLe, tokGt, tokGe:
+		return true
+	case tokIdent:
+		return p.lex.syntax.CEL && p.tok.value == "in"
+	}
+
+	return false
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isComparison() {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryNode{Offset: op.pos, Operator: op.value, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokPlus || p.tok.kind == tokMinus {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryNode{Offset: op.pos, Operator: op.value, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokStar || p.tok.kind == tokSlash || p.tok.kind == tokPercent {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &BinaryNode{Offset: op.pos, Operator: op.value, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNesting {
+		return nil, &expr.SyntaxError{Offset: p.tok.pos, Message: "expression is nested too deeply"}
+	}
+
+	if p.tok.kind == tokNot {
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &NotNode{Offset: pos, Operand: operand}, nil
+	}
+
+	if p.tok.kind == tokMinus {
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &NegateNode{Offset: pos, Operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (Node, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.tok.kind {
+		case tokDot:
+			pos := p.tok.pos
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			if p.tok.kind != tokIdent {
+				return nil, &expr.SyntaxError{Offset: p.tok.pos, Message: "expected property name after ."}
+			}
+
+			name := p.tok
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			if p.lex.syntax.CEL && p.tok.kind == tokLParen {
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+
+				node = &CallNode{Offset: name.pos, Name: name.value, Args: append([]Node{node}, args...)}
+				continue
+			}
+
+			node = &PropertyNode{Offset: pos, Target: node, Name: name.value}
+
+		case tokLBracket:
+			pos := p.tok.pos
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			index, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(tokRBracket); err != nil {
+				return nil, err
+			}
+
+			node = &IndexNode{Offset: pos, Target: node, Index: index}
+
+		case tokLParen:
+			ident, ok := node.(*IdentNode)
+			if !ok {
+				return nil, &expr.SyntaxError{Offset: p.tok.pos, Message: "only named functions can be called"}
+			}
+
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+
+			node = &CallNode{Offset: ident.Offset, Name: ident.Name, Args: args}
+
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *parser) parseArgs() ([]Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	args := []Node{}
+	if p.tok.kind == tokRParen {
+		return args, p.advance()
+	}
+
+	for {
+		arg, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+		if p.tok.kind != tokComma {
+			break
+		}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		value, err := parseNumber(tok.value)
+		if err != nil {
+			return nil, &expr.SyntaxError{Offset: tok.pos, Message: "invalid number " + tok.value}
+		}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		return &LiteralNode{Offset: tok.pos, Value: value}, nil
+
+	case tokString:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		return &LiteralNode{Offset: tok.pos, Value: tok.value}, nil
+
+	case tokIdent:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		switch tok.value {
+		case "true":
+			return &LiteralNode{Offset: tok.pos, Value: true}, nil
+		case "false":
+			return &LiteralNode{Offset: tok.pos, Value: false}, nil
+		case "null":
+			return &LiteralNode{Offset: tok.pos, Value: nil}, nil
+		}
+
+		return &IdentNode{Offset: tok.pos, Name: tok.value}, nil
+
+	case tokLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		node, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	}
+
+	return nil, p.unexpected()
+}
+
+func parseNumber(s string) (interface{}, error) {
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "0x") || strings.HasPrefix(lower, "-0x") {
+		return strconv.ParseInt(s, 0, 64)
+	}
+
+	if strings.ContainsAny(lower, ".e") {
+		return strconv.ParseFloat(s, 64)
+	}
+
+	return strconv.ParseInt(s, 10, 64)
+}
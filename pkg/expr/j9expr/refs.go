@@ -0,0 +1,204 @@
+package j9expr
+
+// References returns the statically known property paths the template reads
+// from its context, e.g. `outputs.build.artifact` yields
+// ["outputs", "build", "artifact"]. A path stops at the first dynamic index.
+func (t *Template) References() [][]string {
+	refs := [][]string{}
+	for _, segment := range t.Segments {
+		if segment.Expr != nil {
+			refs = collectReferences(segment.Expr, refs)
+		}
+	}
+
+	return refs
+}
+
+// Calls returns the names of the functions the template calls, as written,
+// e.g. `success() && contains(x, 'a')` yields ["success", "contains"].
+func (t *Template) Calls() []string {
+	calls := []string{}
+	for _, segment := range t.Segments {
+		if segment.Expr != nil {
+			calls = collectCalls(segment.Expr, calls)
+		}
+	}
+
+	return calls
+}
+
+func collectCalls(node Node, calls []string) []string {
+	switch n := node.(type) {
+	case *CallNode:
+		calls = append(calls, n.Name)
+		for _, arg := range n.Args {
+			calls = collectCalls(arg, calls)
+		}
+
+	case *PropertyNode:
+		return collectCalls(n.Target, calls)
+
+	case *IndexNode:
+		calls = collectCalls(n.Target, calls)
+		return collectCalls(n.Index, calls)
+
+	case *NotNode:
+		return collectCalls(n.Operand, calls)
+
+	case *NegateNode:
+		return collectCalls(n.Operand, calls)
+
+	case *ConditionalNode:
+		calls = collectCalls(n.Cond, calls)
+		calls = collectCalls(n.Then, calls)
+		return collectCalls(n.Else, calls)
+
+	case *BinaryNode:
+		calls = collectCalls(n.Left, calls)
+		return collectCalls(n.Right, calls)
+	}
+
+	return calls
+}
+
+// collectIdents returns the names read from the context, in source order.
+func collectIdents(node Node, idents []*IdentNode) []*IdentNode {
+	switch n := node.(type) {
+	case *IdentNode:
+		return append(idents, n)
+
+	case *PropertyNode:
+		return collectIdents(n.Target, idents)
+
+	case *IndexNode:
+		idents = collectIdents(n.Target, idents)
+		return collectIdents(n.Index, idents)
+
+	case *CallNode:
+		for _, arg := range n.Args {
+			idents = collectIdents(arg, idents)
+		}
+
+	case *NotNode:
+		return collectIdents(n.Operand, idents)
+
+	case *NegateNode:
+		return collectIdents(n.Operand, idents)
+
+	case *ConditionalNode:
+		idents = collectIdents(n.Cond, idents)
+		idents = collectIdents(n.Then, idents)
+		return collectIdents(n.Else, idents)
+
+	case *BinaryNode:
+		idents = collectIdents(n.Left, idents)
+		return collectIdents(n.Right, idents)
+	}
+
+	return idents
+}
+
+func collectReferences(node Node, refs [][]string) [][]string {
+	switch n := node.(type) {
+	case *IdentNode:
+		return append(refs, []string{n.Name})
+
+	case *PropertyNode, *IndexNode:
+		path, root, dynamic := referencePath(n)
+		if path != nil {
+			refs = append(refs, path)
+		} else if root != nil {
+			refs = collectReferences(root, refs)
+		}
+
+		for _, d := range dynamic {
+			refs = collectReferences(d, refs)
+		}
+
+		return refs
+
+	case *CallNode:
+		for _, arg := range n.Args {
+			refs = collectReferences(arg, refs)
+		}
+
+	case *NotNode:
+		return collectReferences(n.Operand, refs)
+
+	case *NegateNode:
+		return collectReferences(n.Operand, refs)
+
+	case *ConditionalNode:
+		refs = collectReferences(n.Cond, refs)
+		refs = collectReferences(n.Then, refs)
+		return collectReferences(n.Else, refs)
+
+	case *BinaryNode:
+		refs = collectReferences(n.Left, refs)
+		return collectReferences(n.Right, refs)
+	}
+
+	return refs
+}
+
+// referencePath walks an accessor chain down to its root. When the root is an
+// identifier the longest static prefix is returned as path; otherwise root is
+// the non-identifier expression at the bottom of the chain. dynamic holds the
+// index expressions that were not string or number literals.
+func referencePath(node Node) ([]string, Node, []Node) {
+	chain := []Node{}
+	current := node
+	for {
+		switch n := current.(type) {
+		case *PropertyNode:
+			chain = append(chain, n)
+			current = n.Target
+			continue
+		case *IndexNode:
+			chain = append(chain, n)
+			current = n.Target
+			continue
+		}
+		break
+	}
+
+	dynamic := []Node{}
+	ident, ok := current.(*IdentNode)
+	if !ok {
+		for _, c := range chain {
+			if in, ok := c.(*IndexNode); ok {
+				dynamic = append(dynamic, in.Index)
+			}
+		}
+
+		return nil, current, dynamic
+	}
+
+	path := []string{ident.Name}
+	static := true
+	for i := len(chain) - 1; i >= 0; i-- {
+		switch n := chain[i].(type) {
+		case *PropertyNode:
+			if static {
+				path = append(path, n.Name)
+			}
+		case *IndexNode:
+			lit, ok := n.Index.(*LiteralNode)
+			if !ok {
+				static = false
+				dynamic = append(dynamic, n.Index)
+				continue
+			}
+
+			if static {
+				if s, ok := lit.Value.(string); ok {
+					path = append(path, s)
+				} else {
+					static = false
+				}
+			}
+		}
+	}
+
+	return path, nil, dynamic
+}
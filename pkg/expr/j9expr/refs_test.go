@@ -0,0 +1,56 @@
+package j9expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		source string
+		want   [][]string
+	}{
+		{"plain text", [][]string{}},
+		{"${{ outputs.build.version }}", [][]string{{"outputs", "build", "version"}}},
+		{"${{ outputs['build'].files[0] }}", [][]string{{"outputs", "build", "files"}}},
+		{"${{ outputs[matrix.os].x }}", [][]string{{"outputs"}, {"matrix", "os"}}},
+		{"${{ contains(env.PATH, vars.bin) }}", [][]string{{"env", "PATH"}, {"vars", "bin"}}},
+		{"${{ !a || b }} and ${{ c == d }}", [][]string{{"a"}, {"b"}, {"c"}, {"d"}}},
+	}
+
+	for _, tt := range tests {
+		compiled, err := New().Compile(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := compiled.(*Template).References(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: References() = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestCalls(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"${{ env.A }}", []string{}},
+		{"${{ 'failure()' == env.A }}", []string{}},
+		{"${{ failure() }}", []string{"failure"}},
+		{"${{ Always() || contains(toJSON(x), 'a') }}", []string{"Always", "contains", "toJSON"}},
+		{"${{ outputs[format('{0}', success())] }}", []string{"format", "success"}},
+		{"${{ !cancelled() && startsWith(b, 'c') }}", []string{"cancelled", "startsWith"}},
+	}
+
+	for _, tt := range tests {
+		compiled, err := New().Compile(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := compiled.(*Template).Calls(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Calls() = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
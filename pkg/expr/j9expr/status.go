@@ -0,0 +1,57 @@
+package j9expr
+
+import "strings"
+
+// Status values the task runner stores under `j9.status` to summarize the
+// tasks a task depends on. A missing value counts as StatusSuccess.
+const (
+	StatusSuccess   = "success"
+	StatusFailure   = "failure"
+	StatusCancelled = "cancelled"
+)
+
+// IsStatusFunction reports whether name is one of the functions that read
+// `j9.status`: success, failure, cancelled and always.
+func IsStatusFunction(name string) bool {
+	switch strings.ToLower(name) {
+	case "success", "failure", "cancelled", "always":
+		return true
+	}
+
+	return false
+}
+
+func registerStatusFunctions(f *Functions) {
+	f.Register("success", statusFunction(StatusSuccess))
+	f.Register("failure", statusFunction(StatusFailure))
+	f.Register("cancelled", statusFunction(StatusCancelled))
+	f.Register("always", fnAlways)
+}
+
+func statusFunction(status string) Function {
+	return func(call *Call, args []interface{}) (interface{}, error) {
+		if err := ArgCount(args, 0, 0); err != nil {
+			return nil, err
+		}
+
+		return statusOf(call) == status, nil
+	}
+}
+
+func statusOf(call *Call) string {
+	if info, ok := Normalize(call.Data[ContextKey]).(map[string]interface{}); ok {
+		if s, ok := info["status"].(string); ok && s != "" {
+			return s
+		}
+	}
+
+	return StatusSuccess
+}
+
+func fnAlways(call *Call, args []interface{}) (interface{}, error) {
+	if err := ArgCount(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+// Limits bounds the work a single evaluation may do. A zero field disables
+// that limit.
+type Limits struct {
+	// MaxSteps is the number of expression nodes evaluated.
+	MaxSteps int
+	// MaxDepth is the nesting of the expression being evaluated and of the
+	// values it produces.
+	MaxDepth int
+	// MaxOutput is the length in bytes of any string produced, or the number
+	// of elements in any array or object produced.
+	MaxOutput int
+}
+
+var DefaultLimits = Limits{
+	MaxSteps:  100000,
+	MaxDepth:  64,
+	MaxOutput: 1 << 20,
+}
+
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("exceeded the maximum %s of %d", e.Limit, e.Max)
+}
+
+// Cancellable is implemented by evaluators that can stop an evaluation once
+// signal is done.
+type Cancellable interface {
+	WithSignal(signal context.Context) Evaluator
+}
+
+// CheckValue reports a LimitError when value is nested deeper than
+// limits.MaxDepth or holds a string or container larger than limits.MaxOutput.
+func (l Limits) CheckValue(value interface{}) error {
+	return l.checkValue(reflect.ValueOf(value), 1)
+}
+
+func (l Limits) checkValue(v reflect.Value, depth int) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if l.MaxOutput > 0 && v.Len() > l.MaxOutput {
+			return &LimitError{Limit: "output size", Max: l.MaxOutput}
+		}
+		return nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return nil
+	}
+
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &LimitError{Limit: "depth", Max: l.MaxDepth}
+	}
+
+	if l.MaxOutput > 0 && v.Len() > l.MaxOutput {
+		return &LimitError{Limit: "output size", Max: l.MaxOutput}
+	}
+
+	if v.Kind() == reflect.Map {
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := l.checkValue(iter.Value(), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if err := l.checkValue(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Tainted reports whether an expression may yield secret material: either it
+// reads the `secrets` context, or its value contains one of the secrets.
+func Tainted(expression primitives.Expression, value interface{}, secrets map[string]string) bool {
+	if r, ok := expression.Compiled().(Referencer); ok {
+		for _, ref := range r.References() {
+			if len(ref) > 0 && strings.EqualFold(ref[0], "secrets") {
+				return true
+			}
+		}
+	}
+
+	if len(secrets) == 0 || value == nil {
+		return false
+	}
+
+	text := ToString(value)
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(text, secret) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,388 @@
+package tmplexpr
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"github.com/jolt9dev/go-jolt9/pkg/expr/j9expr"
+)
+
+const Dialect = "template"
+
+func init() {
+	expr.RegisterDialect(Dialect, func() expr.Dialect {
+		return New()
+	})
+}
+
+// Evaluator implements expr.Dialect using Go's text/template syntax, e.g.
+// `{{ .outputs.build.version }}`. The j9expr function library is available
+// to templates under the same names.
+type Evaluator struct {
+	functions *j9expr.Functions
+	limits    expr.Limits
+	signal    context.Context
+}
+
+// Template is the compiled form of a text/template expression.
+type Template struct {
+	Source string
+	tpl    *template.Template
+}
+
+func New() *Evaluator {
+	return &Evaluator{functions: j9expr.New().Functions(), limits: expr.DefaultLimits}
+}
+
+// SetLimits bounds evaluation. text/template offers no hook per node, so
+// MaxSteps is not enforced; MaxOutput and MaxDepth apply to the rendered text
+// and to function results.
+func (e *Evaluator) SetLimits(limits expr.Limits) *Evaluator {
+	e.limits = limits
+	return e
+}
+
+func (e *Evaluator) Limits() expr.Limits {
+	return e.limits
+}
+
+// WithSignal returns a copy of the evaluator whose evaluations fail once
+// signal is done. The signal is checked before rendering and on every
+// function call.
+func (e *Evaluator) WithSignal(signal context.Context) expr.Evaluator {
+	c := *e
+	c.signal = signal
+	return &c
+}
+
+func (e *Evaluator) Functions() *j9expr.Functions {
+	return e.functions
+}
+
+// Register adds a Go function to the evaluator's function library.
+func (e *Evaluator) Register(name string, fn j9expr.Function) *Evaluator {
+	e.functions.Register(name, fn)
+	return e
+}
+
+func (e *Evaluator) IsExpression(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+func (e *Evaluator) Compile(source string) (interface{}, error) {
+	tpl, err := template.New("expr").Funcs(e.funcs(nil)).Parse(source)
+	if err != nil {
+		return nil, syntaxError(source, err)
+	}
+
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			printNilAsEmpty(t.Tree, t.Tree.Root)
+		}
+	}
+
+	return &Template{Source: source, tpl: tpl}, nil
+}
+
+// valueFunc names the function that printNilAsEmpty appends to actions.
+const valueFunc = "_j9Value"
+
+// printNilAsEmpty makes every action that prints a value pipe it through
+// valueFunc, so that missing keys such as an output that was never set print
+// as an empty string, as in the other dialects, rather than `<no value>`.
+func printNilAsEmpty(tree *parse.Tree, list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 {
+				continue
+			}
+
+			ident := parse.NewIdentifier(valueFunc).SetTree(tree).SetPos(n.Pos)
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{ident}})
+		case *parse.IfNode:
+			printNilAsEmpty(tree, n.List)
+			printNilAsEmpty(tree, n.ElseList)
+		case *parse.RangeNode:
+			printNilAsEmpty(tree, n.List)
+			printNilAsEmpty(tree, n.ElseList)
+		case *parse.WithNode:
+			printNilAsEmpty(tree, n.List)
+			printNilAsEmpty(tree, n.ElseList)
+		}
+	}
+}
+
+func (e *Evaluator) EvalCompiled(compiled interface{}, ctx map[string]interface{}) (interface{}, error) {
+	t, ok := compiled.(*Template)
+	if !ok {
+		return nil, fmt.Errorf("tmplexpr: cannot evaluate compiled value of type %T", compiled)
+	}
+
+	// functions need the evaluation context, so each execution binds them
+	// on a copy of the parsed template.
+	tpl, err := t.tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.checkSignal(); err != nil {
+		return nil, err
+	}
+
+	w := &limitWriter{max: e.limits.MaxOutput}
+	if err := tpl.Funcs(e.funcs(ctx)).Execute(w, ctx); err != nil {
+		return nil, fmt.Errorf("expression %q failed: %w", t.Source, err)
+	}
+
+	return w.sb.String(), nil
+}
+
+func (e *Evaluator) Eval(source string, ctx map[string]interface{}) (string, error) {
+	value, err := e.EvalValue(source, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return expr.ToString(value), nil
+}
+
+func (e *Evaluator) EvalValue(source string, ctx map[string]interface{}) (interface{}, error) {
+	compiled, err := e.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.EvalCompiled(compiled, ctx)
+}
+
+func (e *Evaluator) funcs(data map[string]interface{}) template.FuncMap {
+	funcs := template.FuncMap{valueFunc: func(value interface{}) interface{} {
+		if value == nil {
+			return ""
+		}
+
+		return value
+	}}
+	for _, name := range e.functions.Names() {
+		fn, _ := e.functions.Get(name)
+		call := &j9expr.Call{Name: name, Data: data, Signal: e.signal, Limits: e.limits}
+		funcs[name] = func(args ...interface{}) (interface{}, error) {
+			if err := e.checkSignal(); err != nil {
+				return nil, err
+			}
+
+			for i, arg := range args {
+				args[i] = j9expr.Normalize(arg)
+			}
+
+			result, err := fn(call, args)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := e.limits.CheckValue(result); err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		}
+	}
+
+	return funcs
+}
+
+func (e *Evaluator) checkSignal() error {
+	if e.signal != nil && e.signal.Err() != nil {
+		return fmt.Errorf("evaluation cancelled: %w", e.signal.Err())
+	}
+
+	return nil
+}
+
+type limitWriter struct {
+	sb  strings.Builder
+	max int
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.max > 0 && w.sb.Len()+len(p) > w.max {
+		return 0, &expr.LimitError{Limit: "output size", Max: w.max}
+	}
+
+	return w.sb.Write(p)
+}
+
+var parseErrorPattern = regexp.MustCompile(`^template: expr:(\d+):(?:\d+:)? (.*)$`)
+
+// syntaxError converts a text/template parse error, which only carries a line
+// number, into an expr.SyntaxError pointing at the start of that line.
+func syntaxError(source string, err error) error {
+	m := parseErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return &expr.SyntaxError{Source: source, Message: err.Error()}
+	}
+
+	line, _ := strconv.Atoi(m[1])
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(source[offset:], '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
+	}
+
+	return &expr.SyntaxError{Source: source, Offset: offset, Message: m[2]}
+}
+
+// References returns the context fields the template reads, e.g.
+// `{{ .outputs.build.version }}` yields ["outputs", "build", "version"].
+func (t *Template) References() [][]string {
+	refs := [][]string{}
+	for _, tpl := range t.tpl.Templates() {
+		if tpl.Tree != nil {
+			refs = collectReferences(tpl.Tree.Root, refs)
+		}
+	}
+
+	return refs
+}
+
+func collectReferences(node parse.Node, refs [][]string) [][]string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return refs
+		}
+
+		for _, child := range n.Nodes {
+			refs = collectReferences(child, refs)
+		}
+
+	case *parse.ActionNode:
+		return collectReferences(n.Pipe, refs)
+
+	case *parse.PipeNode:
+		if n == nil {
+			return refs
+		}
+
+		for _, cmd := range n.Cmds {
+			refs = collectReferences(cmd, refs)
+		}
+
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			refs = collectReferences(arg, refs)
+		}
+
+	case *parse.FieldNode:
+		return append(refs, append([]string{}, n.Ident...))
+
+	case *parse.ChainNode:
+		return collectReferences(n.Node, refs)
+
+	case *parse.IfNode:
+		return collectBranch(&n.BranchNode, refs)
+
+	case *parse.RangeNode:
+		return collectBranch(&n.BranchNode, refs)
+
+	case *parse.WithNode:
+		return collectBranch(&n.BranchNode, refs)
+
+	case *parse.TemplateNode:
+		return collectReferences(n.Pipe, refs)
+	}
+
+	return refs
+}
+
+func collectBranch(n *parse.BranchNode, refs [][]string) [][]string {
+	refs = collectReferences(n.Pipe, refs)
+	refs = collectReferences(n.List, refs)
+	return collectReferences(n.ElseList, refs)
+}
+
+// Calls returns the names of the functions the template calls, including
+// builtins such as `and`, e.g. `{{ if success }}` yields ["success"].
+func (t *Template) Calls() []string {
+	calls := []string{}
+	for _, tpl := range t.tpl.Templates() {
+		if tpl.Tree != nil {
+			calls = collectCalls(tpl.Tree.Root, calls)
+		}
+	}
+
+	return calls
+}
+
+func collectCalls(node parse.Node, calls []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return calls
+		}
+
+		for _, child := range n.Nodes {
+			calls = collectCalls(child, calls)
+		}
+
+	case *parse.ActionNode:
+		return collectCalls(n.Pipe, calls)
+
+	case *parse.PipeNode:
+		if n == nil {
+			return calls
+		}
+
+		for _, cmd := range n.Cmds {
+			calls = collectCalls(cmd, calls)
+		}
+
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			calls = collectCalls(arg, calls)
+		}
+
+	case *parse.IdentifierNode:
+		// printNilAsEmpty adds valueFunc; it is not part of the source.
+		if n.Ident != valueFunc {
+			return append(calls, n.Ident)
+		}
+
+	case *parse.ChainNode:
+		return collectCalls(n.Node, calls)
+
+	case *parse.IfNode:
+		return collectBranchCalls(&n.BranchNode, calls)
+
+	case *parse.RangeNode:
+		return collectBranchCalls(&n.BranchNode, calls)
+
+	case *parse.WithNode:
+		return collectBranchCalls(&n.BranchNode, calls)
+
+	case *parse.TemplateNode:
+		return collectCalls(n.Pipe, calls)
+	}
+
+	return calls
+}
+
+func collectBranchCalls(n *parse.BranchNode, calls []string) []string {
+	calls = collectCalls(n.Pipe, calls)
+	calls = collectCalls(n.List, calls)
+	return collectCalls(n.ElseList, calls)
+}
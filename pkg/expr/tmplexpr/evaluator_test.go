@@ -0,0 +1,135 @@
+package tmplexpr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"github.com/jolt9dev/go-jolt9/pkg/expr/j9expr"
+)
+
+func TestEvalValue(t *testing.T) {
+	data := map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"build": map[string]interface{}{"version": "1.2.3"},
+		},
+		"list": []interface{}{"a", "b"},
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		{"field", "v{{ .outputs.build.version }}", "v1.2.3"},
+		{"missing key", "[{{ .outputs.build.missing }}]", "[]"},
+		{"missing task", "[{{ .outputs.nope }}]", "[]"},
+		{"missing key in if", "{{ if .outputs.build.version }}[{{ .outputs.build.missing }}]{{ end }}", "[]"},
+		{"missing key in range", "{{ range .list }}{{ . }}{{ $.outputs.none }}{{ end }}", "ab"},
+		{"pipeline", "{{ .outputs.build.version | printf \"%s!\" }}", "1.2.3!"},
+		{"variable", "{{ $v := .outputs.build.version }}{{ $v }}", "1.2.3"},
+		{"function", "{{ upper .outputs.build.version }}{{ join .list \"-\" }}", "1.2.3a-b"},
+		{"text only", "plain", "plain"},
+	}
+
+	e := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.EvalValue(tt.expr, data)
+			if err != nil {
+				t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalValue(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	compiled, err := New().Compile("{{ if .outputs.a.x }}{{ .env.B }}{{ end }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"outputs", "a", "x"}, {"env", "B"}}
+	if got := compiled.(*Template).References(); !reflect.DeepEqual(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"{{ .env.A }}", []string{}},
+		{"{{ if failure }}yes{{ end }}", []string{"failure"}},
+		{"{{ and (success) (eq .env.A \"always()\") }}", []string{"and", "success", "eq"}},
+		{"{{ with .env.A }}{{ upper . }}{{ else }}{{ always }}{{ end }}", []string{"upper", "always"}},
+	}
+
+	for _, tt := range tests {
+		compiled, err := New().Compile(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := compiled.(*Template).Calls(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Calls() = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ten.txt"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		limits expr.Limits
+		expr   string
+		limit  string
+	}{
+		{"readFile over limit", expr.Limits{MaxOutput: 4}, `{{ readFile "ten.txt" }}`, "output size"},
+		{"readFile at limit", expr.Limits{MaxOutput: 10}, `{{ readFile "ten.txt" }}`, ""},
+		{"rendered output", expr.Limits{MaxOutput: 4}, `{{ "abc" }}{{ "def" }}`, "output size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New().SetLimits(tt.limits)
+			// readFile reads at most MaxOutput+1 bytes when its call carries
+			// the evaluator's limits.
+			var seen expr.Limits
+			e.functions.Register("limits", func(call *j9expr.Call, args []interface{}) (interface{}, error) {
+				seen = call.Limits
+				return "", nil
+			})
+
+			data := map[string]interface{}{j9expr.ContextKey: map[string]interface{}{"workspace": root}}
+			_, err := e.EvalValue("{{ limits }}"+tt.expr, data)
+			if seen != tt.limits {
+				t.Errorf("functions were called with limits %+v, want %+v", seen, tt.limits)
+			}
+
+			if tt.limit == "" {
+				if err != nil {
+					t.Fatalf("EvalValue(%q) failed: %v", tt.expr, err)
+				}
+				return
+			}
+
+			var le *expr.LimitError
+			if !errors.As(err, &le) || le.Limit != tt.limit {
+				t.Errorf("EvalValue(%q) error = %v, want the %s limit", tt.expr, err, tt.limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tree is a structured value, such as a YAML mapping or sequence, whose
+// leaves may be expressions. Root holds map[string]interface{},
+// []interface{}, plain scalars and *AnyExpression leaves.
+type Tree struct {
+	Root interface{}
+}
+
+func (t *Tree) References() [][]string {
+	return treeReferences(t.Root, [][]string{})
+}
+
+func treeReferences(node interface{}, refs [][]string) [][]string {
+	switch n := node.(type) {
+	case *AnyExpression:
+		if r, ok := n.Compiled().(Referencer); ok {
+			refs = append(refs, r.References()...)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(n) {
+			refs = treeReferences(n[key], refs)
+		}
+	case []interface{}:
+		for _, v := range n {
+			refs = treeReferences(v, refs)
+		}
+	}
+
+	return refs
+}
+
+// Copy returns a tree with copies of the expression leaves, see Typed.Copy.
+func (t *Tree) Copy() *Tree {
+	return &Tree{Root: copyTree(t.Root)}
+}
+
+func copyTree(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *AnyExpression:
+		return &AnyExpression{Typed: n.Typed.Copy()}
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(n))
+		for key, v := range n {
+			result[key] = copyTree(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(n))
+		for i, v := range n {
+			result[i] = copyTree(v)
+		}
+		return result
+	}
+
+	return node
+}
+
+// Eval evaluates every expression leaf and returns the resulting plain value.
+func (t *Tree) Eval(evaluator Evaluator, ctx map[string]interface{}) (interface{}, error) {
+	return evalTree(t.Root, evaluator, ctx, "")
+}
+
+func evalTree(node interface{}, evaluator Evaluator, ctx map[string]interface{}, path string) (interface{}, error) {
+	switch n := node.(type) {
+	case *AnyExpression:
+		if err := n.Eval(evaluator, ctx); err != nil {
+			if path == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return n.Value(), nil
+
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(n))
+		for _, key := range sortedKeys(n) {
+			v, err := evalTree(n[key], evaluator, ctx, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = v
+		}
+		return result, nil
+
+	case []interface{}:
+		result := make([]interface{}, len(n))
+		for i, item := range n {
+			v, err := evalTree(item, evaluator, ctx, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	}
+
+	return node, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// source renders the tree with expression leaves shown as their raw text.
+func (t *Tree) source() string {
+	var sb strings.Builder
+	writeTreeSource(&sb, t.Root)
+	return sb.String()
+}
+
+func writeTreeSource(sb *strings.Builder, node interface{}) {
+	switch n := node.(type) {
+	case *AnyExpression:
+		sb.WriteString(n.Raw())
+	case map[string]interface{}:
+		sb.WriteString("{")
+		for i, key := range sortedKeys(n) {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(key)
+			sb.WriteString(": ")
+			writeTreeSource(sb, n[key])
+		}
+		sb.WriteString("}")
+	case []interface{}:
+		sb.WriteString("[")
+		for i, item := range n {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeTreeSource(sb, item)
+		}
+		sb.WriteString("]")
+	default:
+		sb.WriteString(ToString(n))
+	}
+}
@@ -2,6 +2,22 @@ package primitives
 
 import "context"
 
+const (
+	InputTypeString  = "string"
+	InputTypeBool    = "bool"
+	InputTypeInt     = "int"
+	InputTypeInt32   = "int32"
+	InputTypeInt64   = "int64"
+	InputTypeUint    = "uint"
+	InputTypeUint32  = "uint32"
+	InputTypeUint64  = "uint64"
+	InputTypeNumber  = "number"
+	InputTypeFloat32 = "float32"
+	InputTypeFloat64 = "float64"
+	InputTypeArray   = "array"
+	InputTypeObject  = "object"
+)
+
 type InputDescriptor struct {
 	Name        string      `json:"name" yaml:"name"`
 	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
@@ -24,6 +40,7 @@ type Expression interface {
 	Raw() string
 	IsEvaluated() bool
 	Compiled() interface{}
+	IsTainted() bool
 	Error() error
 }
 
@@ -47,6 +64,11 @@ type Int64Expression interface {
 	Int64() int64
 }
 
+type Uint32Expression interface {
+	Expression
+	Uint32() uint32
+}
+
 type Float32Expression interface {
 	Expression
 	Float32() float32
@@ -95,13 +117,14 @@ type LoggingMessageBus interface {
 }
 
 type Context struct {
-	Signal   context.Context
-	Env      map[string]string
-	Vars     *ObjectMap
-	Secrets  map[string]string
-	Services map[string]interface{}
-	Outputs  *ObjectMap
-	Bus      LoggingMessageBus
+	Signal    context.Context
+	Workspace string
+	Env       map[string]string
+	Vars      *ObjectMap
+	Secrets   map[string]string
+	Services  map[string]interface{}
+	Outputs   *ObjectMap
+	Bus       LoggingMessageBus
 }
 
 func (o *ObjectMap) Add(key string, value interface{}) bool {
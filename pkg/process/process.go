@@ -0,0 +1,85 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// DefaultGracePeriod is how long a process is given to exit after SIGTERM
+// before it is killed.
+const DefaultGracePeriod = 10 * time.Second
+
+// Run starts cmd in a process group of its own and waits for it to exit.
+// When signal is done first, the group is sent SIGTERM and, once grace has
+// passed, SIGKILL. The group is killed even when the process exits within
+// grace, as children that ignore SIGTERM outlive it. On Windows the group is
+// a job object, which is terminated right away. The error then wraps
+// signal.Err(), so callers can tell a timeout from a cancellation with
+// errors.Is.
+func Run(signal context.Context, cmd *exec.Cmd, grace time.Duration) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	g := newGroup(cmd)
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		g.close()
+		return err
+	case <-signal.Done():
+	}
+
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	g.terminate()
+	deadline := time.Now().Add(grace)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		// the group outlives its leader, so it is still killed once grace
+		// ends, without holding the caller until then.
+		go g.killAt(deadline)
+		return fmt.Errorf("process %d terminated: %w", cmd.Process.Pid, signal.Err())
+	case <-timer.C:
+	}
+
+	g.kill()
+	<-done
+	g.close()
+	return fmt.Errorf("process %d killed after %s grace period: %w", cmd.Process.Pid, grace, signal.Err())
+}
+
+// groupPollInterval is how often a group whose leader has exited is checked
+// for remaining processes.
+const groupPollInterval = 50 * time.Millisecond
+
+// killAt kills the group at deadline. It stops watching as soon as the group
+// is empty, since its id may then be reused by an unrelated group.
+func (g *group) killAt(deadline time.Time) {
+	defer g.close()
+	for {
+		if !g.alive() {
+			return
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			g.kill()
+			return
+		}
+
+		time.Sleep(min(wait, groupPollInterval))
+	}
+}
@@ -0,0 +1,138 @@
+//go:build linux
+
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		check   func(err error) bool
+	}{
+		{"exits", "exit 0", time.Second, func(err error) bool { return err == nil }},
+		{"exit code", "exit 3", time.Second, func(err error) bool {
+			var exit *exec.ExitError
+			return errors.As(err, &exit) && exit.ExitCode() == 3
+		}},
+		{"terminated", "sleep 5", 50 * time.Millisecond, func(err error) bool {
+			return errors.Is(err, context.DeadlineExceeded) && strings.Contains(err.Error(), "terminated")
+		}},
+		{"killed", "trap '' TERM; sleep 5", 50 * time.Millisecond, func(err error) bool {
+			return errors.Is(err, context.DeadlineExceeded) && strings.Contains(err.Error(), "killed")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			err := Run(signal, exec.Command("sh", "-c", tt.script), 100*time.Millisecond)
+			if !tt.check(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestRunKillsGroupAfterLeaderExits(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	tmpFile := filepath.Join(dir, "pid.tmp")
+	// the leader exits on SIGTERM while its child ignores it and keeps no
+	// pipe to the runner open. The child writes its pid only once the trap
+	// is installed, and renames it into place so it is never read half
+	// written.
+	script := `sh -c 'trap "" TERM; echo $$ > ` + tmpFile + ` && mv ` + tmpFile + ` ` + pidFile + `; while :; do sleep 0.05; done' >/dev/null 2>&1 &
+wait`
+
+	signal, cancel := context.WithCancel(context.Background())
+	go func() {
+		for {
+			if data, err := os.ReadFile(pidFile); err == nil && len(bytes.TrimSpace(data)) > 0 {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	grace := 200 * time.Millisecond
+	err := Run(signal, exec.Command("sh", "-c", script), grace)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "terminated") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !alive(pid) {
+		t.Fatalf("child %d stopped before the grace period ended", pid)
+	}
+
+	deadline := time.Now().Add(grace + 2*time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("child %d was not killed after the grace period", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestGroupKillAtStopsWhenEmpty(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 0")
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGroup(cmd)
+	cmd.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		g.killAt(time.Now().Add(time.Minute))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the group was watched until its deadline after it was empty")
+	}
+}
+
+// alive reports whether pid is running; a zombie left for a parent that does
+// not reap it counts as stopped.
+func alive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+
+	// the state follows the command name, which is wrapped in parentheses.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
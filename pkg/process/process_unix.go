@@ -0,0 +1,42 @@
+//go:build unix
+
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// group is the process group led by the started process, so that children
+// started by a shell are stopped along with it.
+type group struct {
+	pgid int
+}
+
+func newGroup(cmd *exec.Cmd) *group {
+	return &group{pgid: cmd.Process.Pid}
+}
+
+func (g *group) terminate() {
+	syscall.Kill(-g.pgid, syscall.SIGTERM)
+}
+
+func (g *group) kill() {
+	syscall.Kill(-g.pgid, syscall.SIGKILL)
+}
+
+// alive reports whether any process is left in the group. While one is, its
+// id cannot be taken by another group.
+func (g *group) alive() bool {
+	return syscall.Kill(-g.pgid, 0) == nil
+}
+
+func (g *group) close() {}
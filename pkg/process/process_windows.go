@@ -0,0 +1,108 @@
+//go:build windows
+
+package process
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                      = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW          = kernel32.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject  = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject        = kernel32.NewProc("TerminateJobObject")
+	procQueryInformationJobObject = kernel32.NewProc("QueryInformationJobObject")
+)
+
+const (
+	processSetQuota = 0x0100
+
+	// jobObjectBasicAccountingInformation is the JOBOBJECTINFOCLASS of
+	// jobAccounting.
+	jobObjectBasicAccountingInformation = 1
+)
+
+// jobAccounting is JOBOBJECT_BASIC_ACCOUNTING_INFORMATION.
+type jobAccounting struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+}
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// group is a job object holding the started process. The processes it
+// starts join the job as well, so they are stopped along with it. When the
+// job cannot be created only the process itself is stopped.
+type group struct {
+	cmd *exec.Cmd
+	job syscall.Handle
+}
+
+func newGroup(cmd *exec.Cmd) *group {
+	g := &group{cmd: cmd}
+	job, _, _ := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return g
+	}
+
+	process, err := syscall.OpenProcess(processSetQuota|syscall.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return g
+	}
+	defer syscall.CloseHandle(process)
+
+	if ok, _, _ := procAssignProcessToJobObject.Call(job, uintptr(process)); ok == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return g
+	}
+
+	g.job = syscall.Handle(job)
+	return g
+}
+
+// Windows has no SIGTERM; the job is terminated right away.
+func (g *group) terminate() {
+	g.kill()
+}
+
+func (g *group) kill() {
+	if g.job == 0 {
+		g.cmd.Process.Kill()
+		return
+	}
+
+	procTerminateJobObject.Call(uintptr(g.job), 1)
+}
+
+// alive reports whether any process is left in the job.
+func (g *group) alive() bool {
+	if g.job == 0 {
+		return false
+	}
+
+	var info jobAccounting
+	ok, _, _ := procQueryInformationJobObject.Call(uintptr(g.job), jobObjectBasicAccountingInformation, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info), 0)
+	return ok != 0 && info.ActiveProcesses > 0
+}
+
+func (g *group) close() {
+	if g.job != 0 {
+		syscall.CloseHandle(g.job)
+		g.job = 0
+	}
+}
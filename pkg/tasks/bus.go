@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"sync"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+// syncBus serializes access to a bus shared by tasks running side by side.
+type syncBus struct {
+	mu  sync.Mutex
+	bus primitives.LoggingMessageBus
+}
+
+func (b *syncBus) Subscribe(sink primitives.MessageSink) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bus.Subscribe(sink)
+}
+
+func (b *syncBus) Unsubscribe(sink primitives.MessageSink) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bus.Unsubscribe(sink)
+}
+
+func (b *syncBus) Send(msg primitives.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bus.Send(msg)
+}
+
+func (b *syncBus) Enabled(level int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bus.Enabled(level)
+}
+
+func (b *syncBus) SetLogLevel(level int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.SetLogLevel(level)
+}
+
+func (b *syncBus) Tracef(format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.Tracef(format, args...)
+}
+
+func (b *syncBus) TraceErrorf(err error, format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.TraceErrorf(err, format, args...)
+}
+
+func (b *syncBus) Debugf(format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.Debugf(format, args...)
+}
+
+func (b *syncBus) DebugErrorf(err error, format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.DebugErrorf(err, format, args...)
+}
+
+func (b *syncBus) Infof(format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.Infof(format, args...)
+}
+
+func (b *syncBus) InfoErrorf(err error, format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.InfoErrorf(err, format, args...)
+}
+
+func (b *syncBus) Warnf(format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.Warnf(format, args...)
+}
+
+func (b *syncBus) WarnErrorf(err error, format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.WarnErrorf(err, format, args...)
+}
+
+func (b *syncBus) Errorf(err error, format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.Errorf(err, format, args...)
+}
+
+func (b *syncBus) Fatalf(format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.Fatalf(format, args...)
+}
+
+func (b *syncBus) FatalErrorf(err error, format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bus.FatalErrorf(err, format, args...)
+}
@@ -0,0 +1,196 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+// WorkflowCommand is a line a task writes to its stdout to talk to the
+// runner, in the form used by GitHub Actions:
+//
+//	::name key=value,key=value::value
+type WorkflowCommand struct {
+	Name       string
+	Properties map[string]string
+	Value      string
+}
+
+// ParseWorkflowCommand parses line as a workflow command. Property values
+// may escape `%`, `\r`, `\n`, `:` and `,` as %25, %0D, %0A, %3A and %2C, and
+// the value the first three of them.
+func ParseWorkflowCommand(line string) (WorkflowCommand, bool) {
+	line = strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(line, "::") {
+		return WorkflowCommand{}, false
+	}
+
+	header, value, ok := strings.Cut(line[2:], "::")
+	if !ok {
+		return WorkflowCommand{}, false
+	}
+
+	name, properties, _ := strings.Cut(header, " ")
+	if name == "" {
+		return WorkflowCommand{}, false
+	}
+
+	cmd := WorkflowCommand{Name: name, Properties: map[string]string{}, Value: unescapeData(value)}
+	for _, property := range strings.Split(properties, ",") {
+		key, v, ok := strings.Cut(strings.TrimSpace(property), "=")
+		if !ok || key == "" {
+			continue
+		}
+
+		cmd.Properties[key] = unescapeProperty(v)
+	}
+
+	return cmd, true
+}
+
+func unescapeData(s string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%").Replace(s)
+}
+
+func unescapeProperty(s string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%3A", ":", "%2C", ",", "%25", "%").Replace(s)
+}
+
+// AnnotationMessage is a warning, error or notice a task reported with
+// ::warning::, ::error:: or ::notice::, optionally pointing at a file.
+type AnnotationMessage struct {
+	Id        string
+	Level     string
+	Title     string
+	File      string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Text      string
+}
+
+func (m *AnnotationMessage) Kind() string {
+	return "task.annotation"
+}
+
+func (m *AnnotationMessage) String() string {
+	location := m.File
+	if location != "" && m.Line > 0 {
+		location += ":" + strconv.Itoa(m.Line)
+		if m.Column > 0 {
+			location += ":" + strconv.Itoa(m.Column)
+		}
+	}
+
+	text := m.Text
+	if m.Title != "" {
+		text = m.Title + ": " + text
+	}
+
+	if location != "" {
+		return fmt.Sprintf("%s: %s: %s", m.Level, location, text)
+	}
+
+	return fmt.Sprintf("%s: %s", m.Level, text)
+}
+
+// DebugMessage is a line a task wrote with ::debug::.
+type DebugMessage struct {
+	Id   string
+	Text string
+}
+
+func (m *DebugMessage) Kind() string {
+	return "task.debug"
+}
+
+// GroupMessage opens a group of output lines with ::group::title, or closes
+// the open one with ::endgroup:: when End is set.
+type GroupMessage struct {
+	Id    string
+	Title string
+	End   bool
+}
+
+func (m *GroupMessage) Kind() string {
+	return "task.group"
+}
+
+func (m *GroupMessage) String() string {
+	if m.End {
+		return ""
+	}
+
+	return m.Title
+}
+
+// SetOutputMessage reports an output the task set with ::set-output::. The
+// value is masked; the output itself keeps the value as written.
+type SetOutputMessage struct {
+	Id    string
+	Name  string
+	Value string
+}
+
+func (m *SetOutputMessage) Kind() string {
+	return "task.set-output"
+}
+
+// command carries out a workflow command written by the task and returns the
+// message to publish for it. ::add-mask:: publishes nothing, so that the
+// value it hides never reaches the bus. Unknown commands are not handled and
+// stay ordinary output.
+func command(state *TaskState, outputs *primitives.ObjectMap, cmd WorkflowCommand) (primitives.Message, bool) {
+	id := state.Id
+	switch cmd.Name {
+	case "add-mask":
+		state.AddMask(cmd.Value)
+		return nil, true
+
+	case "set-output":
+		name := cmd.Properties["name"]
+		if name == "" {
+			return nil, false
+		}
+
+		outputs.Set(name, cmd.Value)
+		return &SetOutputMessage{Id: id, Name: name, Value: state.Mask(cmd.Value)}, true
+
+	case "group":
+		return &GroupMessage{Id: id, Title: state.Mask(cmd.Value)}, true
+
+	case "endgroup":
+		return &GroupMessage{Id: id, End: true}, true
+
+	case "debug":
+		return &DebugMessage{Id: id, Text: state.Mask(cmd.Value)}, true
+
+	case "warning", "error", "notice":
+		p := cmd.Properties
+		return &AnnotationMessage{
+			Id:        id,
+			Level:     cmd.Name,
+			Title:     state.Mask(p["title"]),
+			File:      p["file"],
+			Line:      atoi(p["line"]),
+			Column:    atoi(p["col"]),
+			EndLine:   atoi(p["endLine"]),
+			EndColumn: atoi(p["endColumn"]),
+			Text:      state.Mask(cmd.Value),
+		}, true
+	}
+
+	return nil, false
+}
+
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
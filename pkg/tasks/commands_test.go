@@ -0,0 +1,182 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+func TestParseWorkflowCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want WorkflowCommand
+		ok   bool
+	}{
+		{
+			name: "value only",
+			line: "::debug::hello",
+			want: WorkflowCommand{Name: "debug", Properties: map[string]string{}, Value: "hello"},
+			ok:   true,
+		},
+		{
+			name: "properties",
+			line: "::warning file=a.go, line=3,col=7::check this",
+			want: WorkflowCommand{Name: "warning", Properties: map[string]string{"file": "a.go", "line": "3", "col": "7"}, Value: "check this"},
+			ok:   true,
+		},
+		{
+			name: "leading whitespace",
+			line: " \t::endgroup::",
+			want: WorkflowCommand{Name: "endgroup", Properties: map[string]string{}},
+			ok:   true,
+		},
+		{
+			name: "value containing ::",
+			line: "::notice::a::b",
+			want: WorkflowCommand{Name: "notice", Properties: map[string]string{}, Value: "a::b"},
+			ok:   true,
+		},
+		{
+			name: "escaped property",
+			line: "::error title=a%3Ab%2Cc%25d%0Ae%0Df::x",
+			want: WorkflowCommand{Name: "error", Properties: map[string]string{"title": "a:b,c%d\ne\rf"}, Value: "x"},
+			ok:   true,
+		},
+		{
+			name: "escaped value keeps : and ,",
+			line: "::set-output name=out::line1%0Aline2%25 %3A%2C",
+			want: WorkflowCommand{Name: "set-output", Properties: map[string]string{"name": "out"}, Value: "line1\nline2% %3A%2C"},
+			ok:   true,
+		},
+		{
+			name: "escaped percent is not unescaped twice",
+			line: "::debug::%250A",
+			want: WorkflowCommand{Name: "debug", Properties: map[string]string{}, Value: "%0A"},
+			ok:   true,
+		},
+		{
+			name: "properties without a value are dropped",
+			line: "::warning file,=x,line=2::w",
+			want: WorkflowCommand{Name: "warning", Properties: map[string]string{"line": "2"}, Value: "w"},
+			ok:   true,
+		},
+		{name: "plain output", line: "building...", ok: false},
+		{name: "not at the start", line: "echo ::debug::x", ok: false},
+		{name: "unterminated", line: "::warning file=a.go", ok: false},
+		{name: "missing name", line: "::::value", ok: false},
+		{name: "name starts with a space", line: ":: debug::value", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseWorkflowCommand(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("got ok %v, want %v", ok, tt.ok)
+			}
+
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    primitives.Message
+		handled bool
+		outputs map[string]string
+		masks   []string
+	}{
+		{
+			name:    "unknown commands stay output",
+			line:    "::frobnicate name=x::y",
+			handled: false,
+		},
+		{
+			name:    "set-output without a name stays output",
+			line:    "::set-output::y",
+			handled: false,
+		},
+		{
+			name:    "add-mask publishes nothing",
+			line:    "::add-mask::s3cr3t",
+			handled: true,
+			masks:   []string{"s3cr3t"},
+		},
+		{
+			name:    "set-output",
+			line:    "::set-output name=version::1.2.3",
+			want:    &SetOutputMessage{Id: "a", Name: "version", Value: "1.2.3"},
+			handled: true,
+			outputs: map[string]string{"version": "1.2.3"},
+		},
+		{
+			name:    "masked set-output",
+			line:    "::set-output name=token::key=hidden",
+			want:    &SetOutputMessage{Id: "a", Name: "token", Value: "key=***"},
+			handled: true,
+			outputs: map[string]string{"token": "key=hidden"},
+		},
+		{
+			name:    "annotation",
+			line:    "::error file=a.go,line=3,col=x,endLine=4,title=Oops::hidden failed",
+			want:    &AnnotationMessage{Id: "a", Level: "error", Title: "Oops", File: "a.go", Line: 3, EndLine: 4, Text: "*** failed"},
+			handled: true,
+		},
+		{
+			name:    "group",
+			line:    "::group::Build",
+			want:    &GroupMessage{Id: "a", Title: "Build"},
+			handled: true,
+		},
+		{
+			name:    "endgroup",
+			line:    "::endgroup::",
+			want:    &GroupMessage{Id: "a", End: true},
+			handled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := ParseWorkflowCommand(tt.line)
+			if !ok {
+				t.Fatalf("%q is not a workflow command", tt.line)
+			}
+
+			state := &TaskState{Id: "a"}
+			state.AddMask("hidden")
+			outputs := &primitives.ObjectMap{}
+			msg, handled := command(state, outputs, cmd)
+			if handled != tt.handled {
+				t.Fatalf("got handled %v, want %v", handled, tt.handled)
+			}
+
+			if !reflect.DeepEqual(msg, tt.want) {
+				t.Errorf("got message %#v, want %#v", msg, tt.want)
+			}
+
+			got := map[string]string{}
+			for _, key := range outputs.Keys() {
+				got[key] = outputs.GetString(key)
+			}
+
+			if len(got) > 0 || len(tt.outputs) > 0 {
+				if !reflect.DeepEqual(got, tt.outputs) {
+					t.Errorf("got outputs %q, want %q", got, tt.outputs)
+				}
+			}
+
+			for _, mask := range tt.masks {
+				if state.Mask(mask) != "***" {
+					t.Errorf("%q is not masked", mask)
+				}
+			}
+		})
+	}
+}
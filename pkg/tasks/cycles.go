@@ -0,0 +1,236 @@
+package tasks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CycleEdge is one `needs` entry that takes part in a cycle. Location is the
+// zero value for needs that were not read from a file.
+type CycleEdge struct {
+	Task     string
+	Need     string
+	Location Location
+}
+
+// Cycle is a closed dependency path, e.g. [a b c a] for a needing b, b
+// needing c and c needing a.
+type Cycle struct {
+	Path  []string
+	Edges []CycleEdge
+}
+
+func (c Cycle) String() string {
+	return strings.Join(c.Path, " -> ")
+}
+
+type CycleError struct {
+	Cycles []Cycle
+}
+
+func (e *CycleError) Error() string {
+	var sb strings.Builder
+	for i, cycle := range e.Cycles {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString("dependency cycle: ")
+		sb.WriteString(cycle.String())
+		for _, edge := range cycle.Edges {
+			sb.WriteString(fmt.Sprintf("\n  %s needs %s", edge.Task, edge.Need))
+			if edge.Location.Line > 0 {
+				sb.WriteString(" on " + edge.Location.String())
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// CyclicDependencies returns a CycleError describing every dependency cycle,
+// or nil when the tasks form a DAG.
+func (o *TaskMap) CyclicDependencies() *CycleError {
+	cycles := o.FindCycles()
+	if len(cycles) == 0 {
+		return nil
+	}
+
+	return &CycleError{Cycles: cycles}
+}
+
+// FindCycles returns one cycle for every strongly connected component of the
+// dependency graph that contains a loop. Components are found with Tarjan's
+// algorithm, visiting tasks and needs in declaration order so that the result
+// is deterministic. Needs on unknown tasks are ignored.
+func (o *TaskMap) FindCycles() []Cycle {
+	// components are emitted in reverse topological order; report them in
+	// the order their first task was declared instead.
+	position := make(map[string]int, len(o.order))
+	for i, id := range o.order {
+		position[id] = i
+	}
+
+	cycles := []Cycle{}
+	for _, component := range o.cyclicComponents() {
+		start := component[0]
+		for _, id := range component {
+			if position[id] < position[start] {
+				start = id
+			}
+		}
+
+		cycles = append(cycles, o.cycleFrom(start, component))
+	}
+
+	slices.SortFunc(cycles, func(a, b Cycle) int {
+		return position[a.Path[0]] - position[b.Path[0]]
+	})
+
+	return cycles
+}
+
+// cyclicComponents returns the strongly connected components that contain a
+// loop: those with more than one task, or a single task that needs itself.
+func (o *TaskMap) cyclicComponents() [][]string {
+	t := &tarjan{
+		tasks:   o,
+		index:   make(map[string]int),
+		lowlink: make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+
+	for _, id := range o.order {
+		if _, visited := t.index[id]; !visited {
+			t.connect(id)
+		}
+	}
+
+	components := [][]string{}
+	for _, component := range t.components {
+		if len(component) > 1 || o.needs(component[0], component[0]) {
+			components = append(components, component)
+		}
+	}
+
+	return components
+}
+
+func (o *TaskMap) needs(id, dep string) bool {
+	task := o.Get(id)
+	if task == nil {
+		return false
+	}
+
+	for _, need := range task.Needs {
+		if need == dep {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cycleFrom finds the shortest path from start back to itself that stays
+// inside component.
+func (o *TaskMap) cycleFrom(start string, component []string) Cycle {
+	inside := make(map[string]bool, len(component))
+	for _, id := range component {
+		inside[id] = true
+	}
+
+	parent := map[string]string{}
+	queue := []string{start}
+	visited := map[string]bool{}
+	last := ""
+	for len(queue) > 0 && last == "" {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dep := range o.Get(id).Needs {
+			if dep == start {
+				last = id
+				break
+			}
+
+			if !inside[dep] || visited[dep] {
+				continue
+			}
+
+			visited[dep] = true
+			parent[dep] = id
+			queue = append(queue, dep)
+		}
+	}
+
+	path := []string{start}
+	for id := last; id != start; id = parent[id] {
+		path = append(path, id)
+	}
+
+	// the walk above collected the path backwards from start.
+	for i, j := 1, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	path = append(path, start)
+	cycle := Cycle{Path: path}
+	for i := 0; i < len(path)-1; i++ {
+		edge := CycleEdge{Task: path[i], Need: path[i+1]}
+		if loc, ok := o.Get(path[i]).NeedLocation(path[i+1]); ok {
+			edge.Location = loc
+		}
+
+		cycle.Edges = append(cycle.Edges, edge)
+	}
+
+	return cycle
+}
+
+type tarjan struct {
+	tasks      *TaskMap
+	next       int
+	index      map[string]int
+	lowlink    map[string]int
+	stack      []string
+	onStack    map[string]bool
+	components [][]string
+}
+
+func (t *tarjan) connect(id string) {
+	t.index[id] = t.next
+	t.lowlink[id] = t.next
+	t.next++
+	t.stack = append(t.stack, id)
+	t.onStack[id] = true
+
+	for _, dep := range t.tasks.Get(id).Needs {
+		if !t.tasks.Has(dep) {
+			continue
+		}
+
+		if _, visited := t.index[dep]; !visited {
+			t.connect(dep)
+			t.lowlink[id] = min(t.lowlink[id], t.lowlink[dep])
+		} else if t.onStack[dep] {
+			t.lowlink[id] = min(t.lowlink[id], t.index[dep])
+		}
+	}
+
+	if t.lowlink[id] != t.index[id] {
+		return
+	}
+
+	component := []string{}
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		component = append(component, top)
+		if top == id {
+			break
+		}
+	}
+
+	t.components = append(t.components, component)
+}
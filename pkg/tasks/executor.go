@@ -0,0 +1,792 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr/j9expr"
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+	"github.com/jolt9dev/go-jolt9/pkg/process"
+)
+
+// FailurePolicy decides what happens to the rest of a run when a task fails.
+type FailurePolicy string
+
+const (
+	// Continue runs every task that does not depend on the failed one.
+	Continue FailurePolicy = "continue"
+
+	// FailFast cancels the running tasks and every task not yet started.
+	FailFast FailurePolicy = "fail-fast"
+)
+
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch p := FailurePolicy(s); p {
+	case "":
+		return Continue, nil
+	case Continue, FailFast:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown failure policy %q", s)
+}
+
+// DelegateResolver returns the delegate that runs a task. descriptor is nil
+// for tasks without `uses`.
+type DelegateResolver func(task *Task, descriptor *TaskDescriptor) (DelegateTask, error)
+
+// DefaultResolver runs tasks with a `run` script in their shell, and tasks
+// whose descriptor has a script file with the interpreter it calls for.
+func DefaultResolver(task *Task, descriptor *TaskDescriptor) (DelegateTask, error) {
+	if descriptor != nil {
+		if descriptor.RunFile == "" {
+			return nil, fmt.Errorf("task %s uses %s, which has no script to run", task.Id, descriptor.Id)
+		}
+
+		return &ScriptTask{Path: descriptor.RunPath()}, nil
+	}
+
+	if task.RunExpr == nil {
+		return nil, fmt.Errorf("task %s has nothing to run", task.Id)
+	}
+
+	shell, err := ParseShell(task.Shell)
+	if err != nil {
+		return nil, fmt.Errorf("task %s: %w", task.Id, err)
+	}
+
+	return &ShellTask{Shell: shell}, nil
+}
+
+// Executor runs the tasks of a TaskMap, starting each one as soon as all of
+// its Needs have succeeded.
+type Executor struct {
+	Tasks       *TaskMap
+	Registry    *TaskRegistry
+	Resolve     DelegateResolver
+	Parallelism int
+
+	// OnFailure is applied when a task fails or times out without
+	// continue-on-error. The zero value is Continue.
+	OnFailure FailurePolicy
+
+	// Timeout is the deadline for the whole run. Tasks still running when it
+	// passes time out; tasks not yet started are cancelled.
+	Timeout time.Duration
+
+	// GracePeriod is how long a task that timed out or was cancelled has to
+	// stop before it is abandoned. Processes get it between SIGTERM and
+	// SIGKILL.
+	GracePeriod time.Duration
+}
+
+// NewExecutor creates an executor. A nil resolve runs tasks with
+// DefaultResolver.
+func NewExecutor(tasks *TaskMap, registry *TaskRegistry, resolve DelegateResolver) *Executor {
+	if resolve == nil {
+		resolve = DefaultResolver
+	}
+
+	return &Executor{
+		Tasks:       tasks,
+		Registry:    registry,
+		Resolve:     resolve,
+		Parallelism: runtime.NumCPU(),
+		GracePeriod: process.DefaultGracePeriod,
+	}
+}
+
+type executorRun struct {
+	executor  *Executor
+	ctx       primitives.Context
+	env       map[string]string
+	position  map[string]int
+	exporters []string
+	outer     context.Context
+	cancel    context.CancelCauseFunc
+	results   map[string]*TaskResult
+	finished  int
+	pending   map[string]int
+	children  map[string][]string
+	ready     []string
+	running   int
+	active    map[string]bool
+	matrices  map[string]int
+	completed chan *TaskResult
+}
+
+// Run executes every task and returns one result per task, in the order the
+// tasks were added. Failed tasks are retried as their retry policy allows,
+// with each attempt recorded on the result. Tasks with a dependency that
+// neither succeeded nor failed with continue-on-error are skipped, unless
+// their `if` calls a status function such as failure() or always(). Tasks
+// not yet started when ctx.Signal is done, or when a task fails under
+// FailFast, are cancelled. Every status transition is published on ctx.Bus as a
+// StatusEvent. Variables and directories a task exports through its J9_ENV
+// and J9_PATH files are added to ctx.Env for every task started after it
+// finished, applied in topological order. An empty ctx.Workspace is the
+// current directory. When the graph cannot be executed at all, no task runs
+// and the error says why; otherwise a *RunError is returned along with the
+// results when any task fails the run.
+func (e *Executor) Run(ctx primitives.Context) ([]*TaskResult, error) {
+	run := &executorRun{
+		executor:  e,
+		ctx:       ctx,
+		results:   make(map[string]*TaskResult),
+		pending:   make(map[string]int),
+		children:  make(map[string][]string),
+		active:    make(map[string]bool),
+		matrices:  make(map[string]int),
+		completed: make(chan *TaskResult),
+	}
+
+	if run.ctx.Signal == nil {
+		run.ctx.Signal = context.Background()
+	}
+
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		run.ctx.Signal, cancel = context.WithTimeout(run.ctx.Signal, e.Timeout)
+		defer cancel()
+	}
+
+	// tasks with a status condition run on the outer signal so that cleanup
+	// tasks still run when the run fails fast.
+	run.outer = run.ctx.Signal
+	run.ctx.Signal, run.cancel = context.WithCancelCause(run.ctx.Signal)
+	defer run.cancel(nil)
+
+	// running tasks report their own transitions and output, so the bus they
+	// share serializes sends.
+	if run.ctx.Bus != nil {
+		run.ctx.Bus = &syncBus{bus: run.ctx.Bus}
+	}
+
+	if run.ctx.Workspace == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+
+		run.ctx.Workspace = wd
+	}
+
+	if run.ctx.Env == nil {
+		run.ctx.Env = make(map[string]string)
+	}
+
+	if run.ctx.Outputs == nil {
+		run.ctx.Outputs = &primitives.ObjectMap{}
+	}
+
+	if run.ctx.Vars == nil {
+		run.ctx.Vars = &primitives.ObjectMap{}
+	}
+
+	// sorting checks for missing needs and cycles on the way.
+	order, err := e.Tasks.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range e.Tasks.Keys() {
+		task := e.Tasks.Get(id)
+		for _, dep := range task.Needs {
+			run.pending[id]++
+			run.children[dep] = append(run.children[dep], id)
+		}
+	}
+
+	run.env = maps.Clone(run.ctx.Env)
+	run.position = make(map[string]int, len(order))
+	for i, task := range order {
+		run.position[task.Id] = i
+	}
+
+	for _, id := range e.Tasks.Keys() {
+		run.results[id] = (&TaskResult{Id: id}).OnTransition(run.publish)
+	}
+
+	for _, id := range e.Tasks.Keys() {
+		if run.pending[id] == 0 {
+			run.queue(id)
+		}
+	}
+
+	parallelism := e.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	for run.finished < e.Tasks.Len() {
+		cancelled := run.outer.Err() != nil
+		for !cancelled && run.running < parallelism {
+			id, ok := run.next()
+			if !ok {
+				break
+			}
+
+			run.start(id)
+		}
+
+		if run.running == 0 {
+			if cancelled {
+				run.cancelRemaining()
+				break
+			}
+
+			if len(run.ready) == 0 {
+				// every remaining task waits on another remaining task.
+				return run.ordered(), fmt.Errorf("tasks %s could not be scheduled due to a dependency cycle", strings.Join(run.remaining(), ", "))
+			}
+
+			continue
+		}
+
+		run.finish(<-run.completed)
+	}
+
+	return run.ordered(), run.err()
+}
+
+// RunError reports a run in which tasks failed, timed out or were cancelled
+// without their failure being allowed.
+type RunError struct {
+	// Failed lists those tasks in the order they were added.
+	Failed []string
+
+	// Cause is why the run stopped early: the failure that made it fail fast,
+	// or the run's cancellation or timeout. It is nil when every task had
+	// the chance to run.
+	Cause error
+}
+
+func (e *RunError) Error() string {
+	msg := fmt.Sprintf("task %s did not succeed", strings.Join(e.Failed, ", "))
+	if len(e.Failed) > 1 {
+		msg = fmt.Sprintf("tasks %s did not succeed", strings.Join(e.Failed, ", "))
+	}
+
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+
+	return msg
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Cause
+}
+
+// err returns a RunError when any task fails the run.
+func (r *executorRun) err() error {
+	failed := []string{}
+	for _, id := range r.executor.Tasks.Keys() {
+		if r.results[id].IsFailure() {
+			failed = append(failed, id)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return &RunError{Failed: failed, Cause: context.Cause(r.ctx.Signal)}
+}
+
+// publish forwards status events to the bus.
+func (r *executorRun) publish(event *StatusEvent) {
+	if r.ctx.Bus == nil {
+		return
+	}
+
+	r.ctx.Bus.Send(event)
+}
+
+// next takes the first ready task that is not held back by its matrix's
+// max-parallel, dropping tasks that were cancelled while they waited.
+func (r *executorRun) next() (string, bool) {
+	for i := 0; i < len(r.ready); i++ {
+		id := r.ready[i]
+		if r.results[id].Status.IsFinished() {
+			r.ready = slices.Delete(r.ready, i, i+1)
+			i--
+			continue
+		}
+
+		task := r.executor.Tasks.Get(id)
+		if task.maxParallel > 0 && r.matrices[task.MatrixOf] >= task.maxParallel {
+			continue
+		}
+
+		r.ready = slices.Delete(r.ready, i, i+1)
+		return id, true
+	}
+
+	return "", false
+}
+
+func (r *executorRun) queue(id string) {
+	r.ready = append(r.ready, id)
+	r.results[id].Queue()
+}
+
+// start launches a task with its own copy of the environment and a snapshot
+// of the outputs produced so far, so that tasks running side by side do not
+// share mutable state.
+func (r *executorRun) start(id string) {
+	task := r.executor.Tasks.Get(id)
+
+	ctx := r.ctx
+	if task.hasStatusCondition() {
+		ctx.Signal = r.outer
+	}
+
+	ctx.Env = make(map[string]string, len(r.ctx.Env))
+	for k, v := range r.ctx.Env {
+		ctx.Env[k] = v
+	}
+
+	ctx.Outputs = &primitives.ObjectMap{}
+	for _, key := range r.ctx.Outputs.Keys() {
+		ctx.Outputs.Set(key, r.ctx.Outputs.Get(key))
+	}
+
+	tctx := &TaskContext{Context: ctx, Needs: make(map[string]*TaskResult, len(task.Needs))}
+	for _, dep := range task.Needs {
+		tctx.Needs[dep] = r.results[dep]
+	}
+
+	tctx.Upstream = r.upstream(task)
+
+	result := r.results[id]
+	result.Start()
+	r.running++
+	r.active[id] = true
+	if task.MatrixOf != "" {
+		r.matrices[task.MatrixOf]++
+	}
+	// the task is evaluated as a copy, so that every run sees its own data.
+	run := task.copy()
+	go func() {
+		r.completed <- r.executor.runTask(tctx, run, result)
+	}()
+}
+
+// upstream summarizes the results of every task that task depends on,
+// directly or not. A cancelled task outweighs a failed one.
+func (r *executorRun) upstream(task *Task) string {
+	status := j9expr.StatusSuccess
+	seen := map[string]bool{}
+	queue := append([]string{}, task.Needs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		result := r.results[id]
+		if result.Status == StatusCancelled {
+			return j9expr.StatusCancelled
+		}
+
+		if result.IsFailure() {
+			status = j9expr.StatusFailure
+		}
+
+		queue = append(queue, r.executor.Tasks.Get(id).Needs...)
+	}
+
+	return status
+}
+
+func (r *executorRun) finish(result *TaskResult) {
+	r.running--
+	delete(r.active, result.Id)
+	if task := r.executor.Tasks.Get(result.Id); task.MatrixOf != "" {
+		r.matrices[task.MatrixOf]--
+	}
+	r.complete(result)
+
+	if result.IsFailure() && result.Status != StatusCancelled && r.executor.OnFailure == FailFast {
+		r.failFast(fmt.Errorf("task %s %s and the run fails fast", result.Id, result.Status))
+	}
+}
+
+// complete records a result and releases the tasks that depend on it. Unless
+// the task succeeded or its failure is allowed, dependents are skipped, except
+// those whose condition calls a status function.
+func (r *executorRun) complete(result *TaskResult) {
+	r.finished++
+	if result.Outputs != nil {
+		r.ctx.Outputs.Set(result.Id, *result.Outputs)
+	}
+
+	if len(result.Env) > 0 || len(result.Path) > 0 {
+		r.export(result.Id)
+	}
+
+	ok := result.Status == StatusSucceeded || result.IsAllowedFailure()
+	for _, child := range r.children[result.Id] {
+		next := r.results[child]
+		if next.Status.IsFinished() {
+			continue
+		}
+
+		if !ok && !r.executor.Tasks.Get(child).hasStatusCondition() {
+			next.Skip(fmt.Sprintf("dependency %s %s", result.Id, result.Status))
+			r.complete(next)
+			continue
+		}
+
+		r.pending[child]--
+		if r.pending[child] == 0 {
+			r.queue(child)
+		}
+	}
+}
+
+// export rebuilds the run's environment from the one it started with and the
+// exports of every task that has finished with any, in topological order so
+// that a task's exports override those of the tasks it depends on. Later
+// directories go in front of earlier ones on PATH.
+func (r *executorRun) export(id string) {
+	r.exporters = append(r.exporters, id)
+	slices.SortFunc(r.exporters, func(a, b string) int {
+		return r.position[a] - r.position[b]
+	})
+
+	env := maps.Clone(r.env)
+	dirs := []string{}
+	for _, id := range r.exporters {
+		result := r.results[id]
+		maps.Copy(env, result.Env)
+		dirs = append(dirs, result.Path...)
+	}
+
+	if len(dirs) > 0 {
+		slices.Reverse(dirs)
+		path, ok := env["PATH"]
+		if !ok {
+			path = os.Getenv("PATH")
+		}
+
+		if path != "" {
+			dirs = append(dirs, path)
+		}
+
+		env["PATH"] = strings.Join(dirs, string(os.PathListSeparator))
+	}
+
+	r.ctx.Env = env
+}
+
+// failFast cancels the running tasks and every task not yet started, apart
+// from those whose condition calls a status function; they still run once
+// their dependencies finish.
+func (r *executorRun) failFast(cause error) {
+	if r.ctx.Signal.Err() != nil {
+		return
+	}
+
+	r.cancel(cause)
+	for _, id := range r.executor.Tasks.Keys() {
+		// running tasks stop through the signal and report back themselves.
+		if r.active[id] {
+			continue
+		}
+
+		result := r.results[id]
+		if result.Status != StatusPending && result.Status != StatusQueued {
+			continue
+		}
+
+		if r.executor.Tasks.Get(id).hasStatusCondition() {
+			continue
+		}
+
+		result.Cancel(cause.Error() + " before the task started")
+		r.complete(result)
+	}
+}
+
+func (r *executorRun) cancelRemaining() {
+	reason := "the run was cancelled before the task started"
+	if errors.Is(r.outer.Err(), context.DeadlineExceeded) {
+		reason = fmt.Sprintf("the run exceeded its %s timeout before the task started", r.executor.Timeout)
+	}
+
+	for _, id := range r.remaining() {
+		r.results[id].Cancel(reason)
+		r.finished++
+	}
+}
+
+// remaining lists the tasks that have not finished. It must only be called
+// while no task is running.
+func (r *executorRun) remaining() []string {
+	ids := []string{}
+	for _, id := range r.executor.Tasks.Keys() {
+		if !r.results[id].Status.IsFinished() {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func (r *executorRun) ordered() []*TaskResult {
+	results := make([]*TaskResult, 0, len(r.results))
+	for _, id := range r.executor.Tasks.Keys() {
+		results = append(results, r.results[id])
+	}
+
+	return results
+}
+
+func (e *Executor) runTask(tctx *TaskContext, task *Task, result *TaskResult) *TaskResult {
+	if task.Uses != "" {
+		if e.Registry == nil {
+			return result.Fail(fmt.Errorf("task %s uses %s but no task registry is configured", task.Id, task.Uses))
+		}
+
+		descriptor, ok := e.Registry.Get(task.Uses)
+		if !ok {
+			return result.Fail(fmt.Errorf("task %s uses unknown task %s", task.Id, task.Uses))
+		}
+
+		tctx.Descriptor = descriptor
+	}
+
+	err := task.Eval(tctx)
+	result.AllowFailure = tctx.State != nil && tctx.State.ContinueOnError
+	if err != nil {
+		return result.Fail(err)
+	}
+
+	if !tctx.State.If {
+		return result.Skip("condition evaluated to false")
+	}
+
+	if e.Resolve == nil {
+		return result.Fail(fmt.Errorf("no delegate resolver is configured to run task %s", task.Id))
+	}
+
+	delegate, err := e.Resolve(task, tctx.Descriptor)
+	if err != nil {
+		return result.Fail(err)
+	}
+
+	tctx.GracePeriod = e.GracePeriod
+	signal := tctx.Signal
+	for number := 1; ; number++ {
+		attempt, exports := e.runAttempt(delegate, *tctx, number)
+		result.Attempts = append(result.Attempts, attempt)
+		if attempt.Status == StatusSucceeded {
+			result.Env = exports.env
+			result.Path = exports.path
+			return result.SetOutputs(&exports.outputs).Finish()
+		}
+
+		// the run was cancelled or passed its own timeout; there is nothing
+		// left to retry for.
+		if signalErr := signal.Err(); signalErr != nil {
+			if errors.Is(signalErr, context.DeadlineExceeded) {
+				return result.TimeOut(attempt.Error)
+			}
+
+			return result.Cancel(context.Cause(signal).Error())
+		}
+
+		if number > int(tctx.State.Retries) {
+			return finishAttempt(result, attempt)
+		}
+
+		retry, err := task.ShouldRetry(tctx, attempt)
+		if err != nil {
+			return result.Fail(err)
+		}
+
+		if !retry {
+			return finishAttempt(result, attempt)
+		}
+
+		timer := time.NewTimer(tctx.State.RetryBackoff.Delay(tctx.State.RetryDelay, number))
+		select {
+		case <-timer.C:
+		case <-signal.Done():
+			timer.Stop()
+			if errors.Is(signal.Err(), context.DeadlineExceeded) {
+				return result.TimeOut(fmt.Errorf("task %s timed out waiting to retry: %w", task.Id, attempt.Error))
+			}
+
+			return result.Cancel(context.Cause(signal).Error())
+		}
+	}
+}
+
+// finishAttempt completes the result with the outcome of its last attempt.
+func finishAttempt(result *TaskResult, attempt TaskAttempt) *TaskResult {
+	if attempt.Status == StatusTimedOut {
+		return result.TimeOut(attempt.Error)
+	}
+
+	return result.Fail(attempt.Error)
+}
+
+// taskExports is what a successful attempt leaves for the tasks after it.
+type taskExports struct {
+	outputs primitives.ObjectMap
+	env     map[string]string
+	path    []string
+}
+
+// runAttempt runs the delegate once, under the task's timeout when it has one.
+// The delegate gets fresh J9_OUTPUT, J9_ENV and J9_PATH files; the entries of
+// J9_OUTPUT are added to the outputs it returns.
+func (e *Executor) runAttempt(delegate DelegateTask, tctx TaskContext, number int) (TaskAttempt, taskExports) {
+	attempt := TaskAttempt{Number: number, StartedAt: time.Now()}
+	if tctx.State.Timeout > 0 {
+		var cancel context.CancelFunc
+		tctx.Signal, cancel = context.WithTimeout(tctx.Signal, tctx.State.Timeout)
+		defer cancel()
+	}
+
+	files, err := newTaskFiles()
+	if err != nil {
+		attempt.FinishedAt = time.Now()
+		attempt.Status = StatusFailed
+		attempt.ExitCode = -1
+		attempt.Error = err
+		return attempt, taskExports{}
+	}
+
+	defer files.remove()
+	for name, path := range files {
+		tctx.State.Env[name] = path
+	}
+
+	exports := taskExports{}
+	exports.outputs, err = e.runDelegate(delegate, &tctx)
+	attempt.FinishedAt = time.Now()
+	attempt.ExitCode = exitCode(err)
+	if err == nil {
+		err = files.collect(&tctx, &exports)
+	}
+
+	signalErr := tctx.Signal.Err()
+	switch {
+	case signalErr == nil && err == nil:
+		attempt.Status = StatusSucceeded
+	case signalErr == nil:
+		attempt.Status = StatusFailed
+		attempt.Error = err
+	case errors.Is(signalErr, context.DeadlineExceeded):
+		if err == nil {
+			err = signalErr
+		}
+
+		attempt.Status = StatusTimedOut
+		attempt.Error = fmt.Errorf("task %s timed out: %w", tctx.State.Id, err)
+	default:
+		attempt.Status = StatusCancelled
+		attempt.Error = context.Cause(tctx.Signal)
+	}
+
+	return attempt, exports
+}
+
+// taskFiles maps J9_OUTPUT, J9_ENV and J9_PATH to the files created for them.
+type taskFiles map[string]string
+
+func newTaskFiles() (taskFiles, error) {
+	files := taskFiles{}
+	for _, name := range []string{OutputEnv, EnvFileEnv, PathFileEnv} {
+		pattern := strings.ToLower(strings.ReplaceAll(name, "_", "-")) + "-*"
+		file, err := os.CreateTemp("", pattern)
+		if err != nil {
+			files.remove()
+			return nil, err
+		}
+
+		file.Close()
+		files[name] = file.Name()
+	}
+
+	return files, nil
+}
+
+func (f taskFiles) remove() {
+	for _, path := range f {
+		os.Remove(path)
+	}
+}
+
+// collect reads the files into exports and validates the outputs.
+func (f taskFiles) collect(tctx *TaskContext, exports *taskExports) error {
+	written, err := ParseOutputFile(f[OutputEnv])
+	if err != nil {
+		return fmt.Errorf("task %s: %w", tctx.State.Id, err)
+	}
+
+	for _, key := range written.Keys() {
+		exports.outputs.Set(key, written.Get(key))
+	}
+
+	if err := validateOutputs(tctx, &exports.outputs); err != nil {
+		return err
+	}
+
+	exports.env, err = ParseEnvFile(f[EnvFileEnv])
+	if err != nil {
+		return fmt.Errorf("task %s: %w", tctx.State.Id, err)
+	}
+
+	exports.path, err = ParsePathFile(f[PathFileEnv])
+	if err != nil {
+		return fmt.Errorf("task %s: %w", tctx.State.Id, err)
+	}
+
+	return nil
+}
+
+type delegateResult struct {
+	outputs primitives.ObjectMap
+	err     error
+}
+
+// runDelegate runs the delegate and waits for it to return. Once the task's
+// signal is done the delegate has the grace period, plus a moment to report
+// back, to return before it is abandoned.
+func (e *Executor) runDelegate(delegate DelegateTask, tctx *TaskContext) (primitives.ObjectMap, error) {
+	done := make(chan delegateResult, 1)
+	go func() {
+		outputs, err := delegate.Run(*tctx)
+		done <- delegateResult{outputs: outputs, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.outputs, r.err
+	case <-tctx.Signal.Done():
+	}
+
+	timer := time.NewTimer(e.GracePeriod + time.Second)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.outputs, r.err
+	case <-timer.C:
+		return primitives.ObjectMap{}, fmt.Errorf("task %s did not stop within %s and was abandoned", tctx.State.Id, e.GracePeriod)
+	}
+}
@@ -0,0 +1,536 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+type delegateFunc func(ctx TaskContext) (primitives.ObjectMap, error)
+
+func (f delegateFunc) Run(ctx TaskContext) (primitives.ObjectMap, error) {
+	return f(ctx)
+}
+
+// recorder runs tasks with delegates from its funcs, by task id, and records
+// the order they started in. Tasks without a func succeed.
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+	funcs map[string]delegateFunc
+}
+
+func (r *recorder) resolve(task *Task, descriptor *TaskDescriptor) (DelegateTask, error) {
+	return delegateFunc(func(ctx TaskContext) (primitives.ObjectMap, error) {
+		r.mu.Lock()
+		r.order = append(r.order, ctx.State.Id)
+		fn := r.funcs[ctx.State.Id]
+		r.mu.Unlock()
+		if fn == nil {
+			return primitives.ObjectMap{}, nil
+		}
+
+		return fn(ctx)
+	}), nil
+}
+
+func parseWorkflow(t *testing.T, src string) *Workflow {
+	t.Helper()
+	w, err := ParseWorkflow([]byte(src), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func statuses(results []*TaskResult) map[string]TaskStatus {
+	m := make(map[string]TaskStatus, len(results))
+	for _, r := range results {
+		m[r.Id] = r.Status
+	}
+
+	return m
+}
+
+func TestExecutorInfersNeedsFromOutputs(t *testing.T) {
+	w := parseWorkflow(t, `
+tasks:
+  deploy:
+    env:
+      VERSION: ${{ outputs.build.version }}
+  build: {}
+`)
+
+	rec := &recorder{funcs: map[string]delegateFunc{
+		"build": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			outputs := primitives.ObjectMap{}
+			outputs.Set("version", "1.2.3")
+			return outputs, nil
+		},
+		"deploy": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			outputs := primitives.ObjectMap{}
+			outputs.Set("version", ctx.State.Env["VERSION"])
+			return outputs, nil
+		},
+	}}
+
+	e := w.NewExecutor(nil, rec.resolve)
+	e.Parallelism = 1
+	results, err := e.Run(primitives.Context{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.order) != 2 || rec.order[0] != "build" || rec.order[1] != "deploy" {
+		t.Errorf("tasks ran in order %v, want [build deploy]", rec.order)
+	}
+
+	if got := results[0].Outputs.GetString("version"); got != "1.2.3" {
+		t.Errorf("deploy saw version %q, want 1.2.3", got)
+	}
+}
+
+func TestExecutorRejectsInvalidGraphs(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		check func(error) bool
+	}{
+		{
+			name: "missing need",
+			yaml: "tasks:\n  a:\n    needs: [b]\n",
+			check: func(err error) bool {
+				var missing *MissingDependencyError
+				return errors.As(err, &missing)
+			},
+		},
+		{
+			name: "cycle",
+			yaml: "tasks:\n  a:\n    needs: [b]\n  b:\n    needs: [a]\n",
+			check: func(err error) bool {
+				var cycle *CycleError
+				return errors.As(err, &cycle)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			_, err := parseWorkflow(t, tt.yaml).NewExecutor(nil, rec.resolve).Run(primitives.Context{})
+			if !tt.check(err) {
+				t.Errorf("Run error = %v", err)
+			}
+
+			if len(rec.order) > 0 {
+				t.Errorf("tasks %v ran", rec.order)
+			}
+		})
+	}
+}
+
+func TestExecutorRunsInDependencyOrder(t *testing.T) {
+	const src = `
+tasks:
+  deploy:
+    needs: [test, lint]
+  test:
+    needs: [build]
+  lint:
+    needs: [build]
+  build: {}
+  docs: {}
+`
+
+	t.Run("sequential", func(t *testing.T) {
+		rec := &recorder{}
+		e := parseWorkflow(t, src).NewExecutor(nil, rec.resolve)
+		e.Parallelism = 1
+		results, err := e.Run(primitives.Context{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"build", "docs", "test", "lint", "deploy"}
+		if !reflect.DeepEqual(rec.order, want) {
+			t.Errorf("tasks ran in order %v, want %v", rec.order, want)
+		}
+
+		ids := []string{}
+		for _, r := range results {
+			ids = append(ids, r.Id)
+			if r.Status != StatusSucceeded {
+				t.Errorf("%s %s", r.Id, r.Status)
+			}
+		}
+
+		if want := []string{"deploy", "test", "lint", "build", "docs"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("results in order %v, want the declaration order %v", ids, want)
+		}
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		var mu sync.Mutex
+		finished := map[string]bool{}
+		w := parseWorkflow(t, src)
+		e := w.NewExecutor(nil, func(task *Task, descriptor *TaskDescriptor) (DelegateTask, error) {
+			return delegateFunc(func(ctx TaskContext) (primitives.ObjectMap, error) {
+				mu.Lock()
+				for _, need := range task.Needs {
+					if !finished[need] {
+						t.Errorf("%s started before %s finished", task.Id, need)
+					}
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				finished[task.Id] = true
+				mu.Unlock()
+				return primitives.ObjectMap{}, nil
+			}), nil
+		})
+
+		e.Parallelism = 4
+		if _, err := e.Run(primitives.Context{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(finished) != 5 {
+			t.Errorf("%d tasks finished, want 5", len(finished))
+		}
+	})
+}
+
+func TestExecutorRunsAgainWithOtherData(t *testing.T) {
+	w := parseWorkflow(t, `
+tasks:
+  a:
+    if: ${{ env.N != '2' }}
+    env:
+      M: m${{ env.N }}
+    run: echo N=${{ env.N }}
+`)
+
+	var runs []string
+	rec := &recorder{funcs: map[string]delegateFunc{
+		"a": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			runs = append(runs, ctx.State.RunExpr+" "+ctx.State.Env["M"])
+			return primitives.ObjectMap{}, nil
+		},
+	}}
+
+	e := w.NewExecutor(nil, rec.resolve)
+	for _, n := range []string{"0", "1", "2"} {
+		if _, err := e.Run(primitives.Context{Env: map[string]string{"N": n}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"echo N=0 m0", "echo N=1 m1"}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("got runs %q, want %q", runs, want)
+	}
+}
+
+// exportFunc writes env entries and path directories to the task's J9_ENV and
+// J9_PATH files, and fails with err when it is set.
+func exportFunc(env, path string, err error) delegateFunc {
+	return func(ctx TaskContext) (primitives.ObjectMap, error) {
+		if e := os.WriteFile(ctx.State.Env[EnvFileEnv], []byte(env), 0o644); e != nil {
+			return primitives.ObjectMap{}, e
+		}
+
+		if e := os.WriteFile(ctx.State.Env[PathFileEnv], []byte(path), 0o644); e != nil {
+			return primitives.ObjectMap{}, e
+		}
+
+		return primitives.ObjectMap{}, err
+	}
+}
+
+func TestExecutorPropagatesExports(t *testing.T) {
+	w := parseWorkflow(t, `
+tasks:
+  last:
+    needs: [second, other]
+  second:
+    needs: [first]
+  first:
+    retries: 1
+  other: {}
+`)
+
+	var mu sync.Mutex
+	seen := map[string]map[string]string{}
+	record := func(next delegateFunc) delegateFunc {
+		return func(ctx TaskContext) (primitives.ObjectMap, error) {
+			mu.Lock()
+			seen[ctx.State.Id] = maps.Clone(ctx.State.Env)
+			mu.Unlock()
+			return next(ctx)
+		}
+	}
+
+	attempts := 0
+	rec := &recorder{funcs: map[string]delegateFunc{
+		"first": record(func(ctx TaskContext) (primitives.ObjectMap, error) {
+			attempts++
+			// the failed attempt's exports are dropped with it.
+			if attempts == 1 {
+				return exportFunc("A=failed\nFAILED=1\n", "/failed\n", errors.New("flaky"))(ctx)
+			}
+
+			return exportFunc("A=1\nB=1\n\nA=first\n", "/first/a\n\n/first/b\n", nil)(ctx)
+		}),
+		"second": record(exportFunc("A=second\n", "/second\n", nil)),
+		"other":  record(exportFunc("C=other\n", "", nil)),
+		"last":   record(exportFunc("", "", nil)),
+	}}
+
+	e := w.NewExecutor(nil, rec.resolve)
+	e.Parallelism = 1
+	results, err := e.Run(primitives.Context{Env: map[string]string{"PATH": "/usr/bin", "A": "run"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range results {
+		if r.Status != StatusSucceeded {
+			t.Fatalf("%s %s: %v", r.Id, r.Status, r.Error)
+		}
+	}
+
+	sep := string(os.PathListSeparator)
+	tests := []struct {
+		id   string
+		want map[string]string
+	}{
+		{"first", map[string]string{"A": "run", "PATH": "/usr/bin"}},
+		{"second", map[string]string{"A": "first", "B": "1", "PATH": strings.Join([]string{"/first/b", "/first/a", "/usr/bin"}, sep)}},
+		{"last", map[string]string{"A": "second", "B": "1", "C": "other", "PATH": strings.Join([]string{"/second", "/first/b", "/first/a", "/usr/bin"}, sep)}},
+	}
+
+	for _, tt := range tests {
+		env := seen[tt.id]
+		for k, v := range tt.want {
+			if env[k] != v {
+				t.Errorf("%s saw %s=%q, want %q", tt.id, k, env[k], v)
+			}
+		}
+
+		if _, ok := env["FAILED"]; ok {
+			t.Errorf("%s saw the exports of a failed attempt", tt.id)
+		}
+	}
+}
+
+// exitError is an error carrying an exit code, like *exec.ExitError.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("flaky exit %d", int(e))
+}
+
+func (e exitError) ExitCode() int {
+	return int(e)
+}
+
+func TestExecutorRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		codes  []int
+		status TaskStatus
+		gaps   []time.Duration
+	}{
+		{
+			name:   "succeeds on a retry",
+			yaml:   "retries: 2",
+			codes:  []int{1, 1, 0},
+			status: StatusSucceeded,
+		},
+		{
+			name:   "runs out of retries",
+			yaml:   "retries: 1",
+			codes:  []int{1, 1, 0},
+			status: StatusFailed,
+		},
+		{
+			name:   "constant delay",
+			yaml:   "retries: 2\n    retry-delay: 30ms",
+			codes:  []int{1, 1, 0},
+			status: StatusSucceeded,
+			gaps:   []time.Duration{30 * time.Millisecond, 30 * time.Millisecond},
+		},
+		{
+			name:   "linear backoff",
+			yaml:   "retries: 2\n    retry-delay: 20ms\n    retry-backoff: linear",
+			codes:  []int{1, 1, 0},
+			status: StatusSucceeded,
+			gaps:   []time.Duration{20 * time.Millisecond, 40 * time.Millisecond},
+		},
+		{
+			name:   "retry-on exit codes",
+			yaml:   "retries: 5\n    retry-on: [3]",
+			codes:  []int{3, 4, 0},
+			status: StatusFailed,
+		},
+		{
+			name:   "retry-on sees the failed attempt",
+			yaml:   "retries: 5\n    retry-on: ${{ attempt.exitCode == 3 && attempt.number < 3 && contains(attempt.error, 'flaky') }}",
+			codes:  []int{3, 3, 3, 0},
+			status: StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := parseWorkflow(t, "tasks:\n  a:\n    "+tt.yaml+"\n")
+			var starts []time.Time
+			rec := &recorder{funcs: map[string]delegateFunc{
+				"a": func(ctx TaskContext) (primitives.ObjectMap, error) {
+					code := tt.codes[len(starts)]
+					starts = append(starts, time.Now())
+					if code != 0 {
+						return primitives.ObjectMap{}, exitError(code)
+					}
+
+					return primitives.ObjectMap{}, nil
+				},
+			}}
+
+			results, err := w.NewExecutor(nil, rec.resolve).Run(primitives.Context{})
+			if err != nil && tt.status == StatusSucceeded {
+				t.Fatal(err)
+			}
+
+			r := results[0]
+			if r.Status != tt.status {
+				t.Errorf("got status %s, want %s", r.Status, tt.status)
+			}
+
+			if len(r.Attempts) != len(starts) {
+				t.Fatalf("recorded %d attempts for %d runs", len(r.Attempts), len(starts))
+			}
+
+			for i, attempt := range r.Attempts {
+				if attempt.Number != i+1 || attempt.ExitCode != tt.codes[i] {
+					t.Errorf("attempt %d: got number %d and exit code %d, want exit code %d", i+1, attempt.Number, attempt.ExitCode, tt.codes[i])
+				}
+			}
+
+			for i, gap := range tt.gaps {
+				if got := starts[i+1].Sub(starts[i]); got < gap {
+					t.Errorf("retry %d started %s after the attempt before it, want at least %s", i+1, got, gap)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutorFailFast(t *testing.T) {
+	w := parseWorkflow(t, `
+on-failure: fail-fast
+tasks:
+  build: {}
+  slow: {}
+  later: {}
+  cleanup:
+    needs: [slow]
+    if: ${{ always() }}
+`)
+
+	rec := &recorder{funcs: map[string]delegateFunc{
+		"build": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			return primitives.ObjectMap{}, errors.New("broken")
+		},
+		"slow": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			<-ctx.Signal.Done()
+			return primitives.ObjectMap{}, ctx.Signal.Err()
+		},
+	}}
+
+	e := w.NewExecutor(nil, rec.resolve)
+	e.Parallelism = 2
+	results, err := e.Run(primitives.Context{})
+
+	want := map[string]TaskStatus{
+		"build":   StatusFailed,
+		"slow":    StatusCancelled,
+		"later":   StatusCancelled,
+		"cleanup": StatusSucceeded,
+	}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statuses %v, want %v", got, want)
+	}
+
+	if slices.Contains(rec.order, "later") {
+		t.Errorf("later started after build failed: %v", rec.order)
+	}
+
+	var runErr *RunError
+	if !errors.As(err, &runErr) {
+		t.Fatalf("Run error = %v, want a RunError", err)
+	}
+
+	if want := []string{"build", "slow", "later"}; !reflect.DeepEqual(runErr.Failed, want) {
+		t.Errorf("got failed tasks %v, want %v", runErr.Failed, want)
+	}
+
+	if runErr.Cause == nil || !strings.Contains(runErr.Cause.Error(), "task build failed and the run fails fast") {
+		t.Errorf("got cause %v, want the failure of build", runErr.Cause)
+	}
+}
+
+func TestExecutorContinueOnError(t *testing.T) {
+	w := parseWorkflow(t, `
+tasks:
+  lint:
+    continue-on-error: true
+  build:
+    needs: [lint]
+  test: {}
+  deploy:
+    needs: [test]
+`)
+
+	rec := &recorder{funcs: map[string]delegateFunc{
+		"lint": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			return primitives.ObjectMap{}, errors.New("style")
+		},
+		"test": func(ctx TaskContext) (primitives.ObjectMap, error) {
+			return primitives.ObjectMap{}, errors.New("assertion")
+		},
+	}}
+
+	results, err := w.NewExecutor(nil, rec.resolve).Run(primitives.Context{})
+	want := map[string]TaskStatus{
+		"lint":   StatusFailed,
+		"build":  StatusSucceeded,
+		"test":   StatusFailed,
+		"deploy": StatusSkipped,
+	}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statuses %v, want %v", got, want)
+	}
+
+	if got := results[0].Outcome(); got != "failed (allowed)" {
+		t.Errorf("lint outcome %q, want failed (allowed)", got)
+	}
+
+	var runErr *RunError
+	if !errors.As(err, &runErr) || !reflect.DeepEqual(runErr.Failed, []string{"test"}) || runErr.Cause != nil {
+		t.Errorf("Run error = %#v, want a RunError for test alone", err)
+	}
+}
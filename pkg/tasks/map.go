@@ -3,6 +3,9 @@ package tasks
 import (
 	"fmt"
 	"slices"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
 )
 
 type TaskMap struct {
@@ -75,52 +78,280 @@ func (o *TaskMap) Values() []Task {
 	return values
 }
 
+// Flatten returns targets and everything they transitively need, in
+// topological order. With no targets every task is returned.
 func (o *TaskMap) Flatten(targets []Task) ([]Task, error) {
+	order, err := o.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	include := make(map[string]bool, len(o.order))
 	if len(targets) == 0 {
-		targets = o.Values()
+		for _, id := range o.order {
+			include[id] = true
+		}
+	}
+
+	stack := []string{}
+	for _, target := range targets {
+		stack = append(stack, target.Id)
+	}
+
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if include[id] {
+			continue
+		}
+
+		include[id] = true
+		if task := o.Get(id); task != nil {
+			stack = append(stack, task.Needs...)
+		}
+	}
+
+	results := []Task{}
+	for _, task := range order {
+		if include[task.Id] {
+			results = append(results, *task)
+		}
 	}
 
-	return flatten(*o, targets)
+	return results, nil
 }
 
 type MissingDependencyError struct {
 	message string
-	Tasks   *MissingDepResult
+	Tasks   []*MissingDepResult
 }
 
+// MissingDepResult lists the needs of one task that name no known task.
+// Suggestions maps each missing need to similarly named tasks.
 type MissingDepResult struct {
-	Task    *Task
-	Missing []string
+	Task        *Task
+	Missing     []string
+	Suggestions map[string][]string
 }
 
 func (e *MissingDependencyError) Error() string {
-	return e.message
+	var sb strings.Builder
+	sb.WriteString(e.message)
+	for _, result := range e.Tasks {
+		for _, dep := range result.Missing {
+			sb.WriteString(fmt.Sprintf("\n  task %s needs unknown task %s", result.Task.Id, dep))
+			if loc, ok := result.Task.NeedLocation(dep); ok {
+				sb.WriteString(" on " + loc.String())
+			}
+
+			if suggestions := result.Suggestions[dep]; len(suggestions) > 0 {
+				sb.WriteString(fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, " or ")))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// ByTask maps each task id to its missing needs.
+func (e *MissingDependencyError) ByTask() map[string][]string {
+	result := make(map[string][]string, len(e.Tasks))
+	for _, task := range e.Tasks {
+		result[task.Task.Id] = task.Missing
+	}
+
+	return result
 }
 
+// MissingDependencies reports every task, in declaration order, whose needs
+// name a task that does not exist, or nil when all needs resolve.
 func (o *TaskMap) MissingDependencies() *MissingDependencyError {
-	missing := &MissingDepResult{}
-	for _, task := range o.tasks {
+	results := []*MissingDepResult{}
+	for _, id := range o.order {
+		task := o.tasks[id]
+		var result *MissingDepResult
 		for _, dep := range task.Needs {
-			if !o.Has(dep) {
-				if missing.Task == nil {
-					missing.Task = task
-				}
+			if o.Has(dep) {
+				continue
+			}
+
+			if result == nil {
+				result = &MissingDepResult{Task: task, Suggestions: make(map[string][]string)}
+				results = append(results, result)
+			}
 
-				missing.Missing = append(missing.Missing, dep)
+			result.Missing = append(result.Missing, dep)
+			if suggestions := o.suggest(dep); len(suggestions) > 0 {
+				result.Suggestions[dep] = suggestions
 			}
 		}
 	}
 
-	if missing.Task != nil {
+	if len(results) > 0 {
 		return &MissingDependencyError{
 			message: "missing dependencies",
-			Tasks:   missing,
+			Tasks:   results,
 		}
 	}
 
 	return nil
 }
 
+// suggest returns the task ids closest to name by edit distance, ignoring
+// case, that are close enough to be a likely typo.
+func (o *TaskMap) suggest(name string) []string {
+	limit := max(1, len(name)/3)
+	best := limit + 1
+	suggestions := []string{}
+	for _, id := range o.order {
+		d := editDistance(strings.ToLower(name), strings.ToLower(id))
+		switch {
+		case d > limit:
+		case d < best:
+			best = d
+			suggestions = []string{id}
+		case d == best:
+			suggestions = append(suggestions, id)
+		}
+	}
+
+	return suggestions
+}
+
+// editDistance is the optimal string alignment distance between a and b: the
+// Levenshtein distance with swapping two adjacent characters counted as one
+// edit, since that is a common typo.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+type DependencyWarning struct {
+	Task       string
+	Key        string
+	Dependency string
+	Reference  string
+	Message    string
+}
+
+func (w DependencyWarning) String() string {
+	return fmt.Sprintf("task %s %s references %s: %s", w.Task, w.Key, w.Reference, w.Message)
+}
+
+// InferDependencies adds an edge to Needs for every `outputs.<id>` reference
+// found in a task's compiled expressions. When the matrices have already been
+// expanded, a reference to a matrix task adds an edge to each of its
+// instances. References that cannot be satisfied, because the task is unknown
+// or could not have run earlier, are returned as warnings and no edge is
+// added for them.
+func (o *TaskMap) InferDependencies() []DependencyWarning {
+	instances := map[string][]string{}
+	for _, id := range o.order {
+		if parent := o.tasks[id].MatrixOf; parent != "" {
+			instances[parent] = append(instances[parent], id)
+		}
+	}
+
+	warnings := []DependencyWarning{}
+	for _, id := range o.order {
+		task := o.tasks[id]
+		for _, te := range task.expressions() {
+			referencer, ok := te.expr.Compiled().(expr.Referencer)
+			if !ok {
+				continue
+			}
+
+			for _, path := range referencer.References() {
+				if len(path) < 2 || path[0] != "outputs" {
+					continue
+				}
+
+				ref := path[1]
+				deps := []string{ref}
+				if ids, ok := instances[ref]; ok && !o.Has(ref) {
+					deps = ids
+				}
+
+				for _, dep := range deps {
+					warning := DependencyWarning{
+						Task:       id,
+						Key:        te.key,
+						Dependency: dep,
+						Reference:  strings.Join(path, "."),
+					}
+
+					switch {
+					case dep == id || ref == task.MatrixOf:
+						warning.Message = "a task cannot read its own outputs"
+					case slices.Contains(task.Needs, dep):
+						continue
+					case !o.Has(dep):
+						warning.Message = fmt.Sprintf("task %s does not exist", dep)
+					case o.reaches(dep, id):
+						warning.Message = fmt.Sprintf("task %s depends on %s and cannot have run earlier", dep, id)
+					default:
+						task.Needs = append(task.Needs, dep)
+						continue
+					}
+
+					warnings = append(warnings, warning)
+					break
+				}
+			}
+		}
+	}
+
+	return warnings
+}
+
+// reaches reports whether target is reachable from start by following Needs.
+func (o *TaskMap) reaches(start, target string) bool {
+	visited := map[string]bool{}
+	stack := []string{start}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == target {
+			return true
+		}
+
+		if visited[id] {
+			continue
+		}
+
+		visited[id] = true
+		if task := o.Get(id); task != nil {
+			stack = append(stack, task.Needs...)
+		}
+	}
+
+	return false
+}
+
 func (o *TaskMap) Len() int {
 	return len(o.order)
 }
@@ -152,105 +383,26 @@ func (o *TaskMap) At(index int) (string, Task, bool) {
 	return key, *value, ok
 }
 
+// FindCyclicalReferences returns the tasks that are part of a dependency
+// cycle, in declaration order. See FindCycles for the cycles themselves.
 func (o *TaskMap) FindCyclicalReferences() []Task {
-	cycles := []Task{}
-	stack := []*Task{}
-
-	for _, task := range o.tasks {
-		if !findCycle(task, &stack, *o) {
-			cycles = append(cycles, *task)
+	inCycle := map[string]bool{}
+	for _, component := range o.cyclicComponents() {
+		for _, id := range component {
+			inCycle[id] = true
 		}
 	}
 
-	if len(cycles) == 0 {
+	if len(inCycle) == 0 {
 		return nil
 	}
 
-	return cycles
-}
-
-func findCycle(task *Task, stack *[]*Task, tasks TaskMap) bool {
-	index := -1
-	s := *stack
-	for i, t := range s {
-		if t.Id == task.Id {
-			index = i
-			break
-		}
-	}
-
-	if index >= 0 {
-		return false
-	}
-
-	s = append(s, task)
-	*stack = s
-	for _, dep := range task.Needs {
-		if !tasks.Has(dep) {
-			continue
-		}
-
-		child := tasks.Get(dep)
-		if child == nil {
-			continue
-		}
-
-		result := findCycle(child, stack, tasks)
-		if !result {
-			return false
-		}
-	}
-
-	s = s[:len(s)-1]
-	*stack = s
-
-	return true
-}
-
-func flatten(tasks TaskMap, set []Task) ([]Task, error) {
-	results := []Task{}
-	for _, task := range set {
-		for _, dep := range task.Needs {
-			if !tasks.Has(dep) {
-				continue
-			}
-
-			child := tasks.Get(dep)
-			if child == nil {
-				return nil, fmt.Errorf("task %s depends on missing task %s", task.Id, dep)
-			}
-
-			childResult, err := flatten(tasks, []Task{*child})
-			if err != nil {
-				return nil, err
-			}
-
-			results = append(results, childResult...)
-			found := false
-			for _, next := range results {
-				if next.Id == child.Id {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				results = append(results, *child)
-			}
-		}
-
-		found := false
-		for _, next := range results {
-			if next.Id == task.Id {
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			results = append(results, task)
+	cycles := []Task{}
+	for _, id := range o.order {
+		if inCycle[id] {
+			cycles = append(cycles, *o.tasks[id])
 		}
 	}
 
-	return results, nil
+	return cycles
 }
@@ -0,0 +1,59 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferDependencies(t *testing.T) {
+	w := parseWorkflow(t, `
+tasks:
+  build: {}
+  test:
+    env:
+      A: ${{ outputs.build.a }}
+  deploy:
+    needs: [test]
+    if: ${{ outputs.build.ok && outputs.deploy.x }}
+  lint:
+    needs: [deploy]
+    with:
+      b: ${{ outputs.nope.b }}
+  early:
+    env:
+      C: ${{ outputs.late.c }}
+  late:
+    needs: [early]
+`)
+
+	want := map[string][]string{
+		"build":  {},
+		"test":   {"build"},
+		"deploy": {"test", "build"},
+		"lint":   {"deploy"},
+		"early":  {},
+		"late":   {"early"},
+	}
+
+	for id, needs := range want {
+		if got := w.Tasks.Get(id).Needs; !reflect.DeepEqual(got, needs) {
+			t.Errorf("%s needs %v, want %v", id, got, needs)
+		}
+	}
+
+	messages := []string{}
+	for _, warning := range w.Warnings {
+		messages = append(messages, warning.String())
+	}
+
+	wantMessages := []string{
+		"task deploy if references outputs.deploy.x: a task cannot read its own outputs",
+		"task lint with.b references outputs.nope.b: task nope does not exist",
+		"task early env.C references outputs.late.c: task late depends on early and cannot have run earlier",
+	}
+
+	if strings.Join(messages, "\n") != strings.Join(wantMessages, "\n") {
+		t.Errorf("warnings:\n%s\nwant:\n%s", strings.Join(messages, "\n"), strings.Join(wantMessages, "\n"))
+	}
+}
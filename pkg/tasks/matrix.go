@@ -0,0 +1,329 @@
+package tasks
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"gopkg.in/yaml.v3"
+)
+
+// MatrixAxis is one named list of values in a matrix.
+type MatrixAxis struct {
+	Name   string
+	Values []interface{}
+}
+
+// Matrix expands a task into one instance per combination of its axes.
+// Exclude entries remove the combinations they match. Include entries add
+// their extra values to every combination they match, or become combinations
+// of their own when they match none. MaxParallel limits how many instances
+// run at once; zero means no limit beyond the executor's.
+type Matrix struct {
+	Axes        []MatrixAxis
+	Include     []map[string]interface{}
+	Exclude     []map[string]interface{}
+	MaxParallel int
+}
+
+// Combinations returns the values of every instance, in axis order with the
+// first axis varying slowest, followed by combinations added by Include.
+func (m *Matrix) Combinations() []map[string]interface{} {
+	combinations := []map[string]interface{}{}
+	if len(m.Axes) > 0 {
+		combinations = append(combinations, map[string]interface{}{})
+	}
+
+	for _, axis := range m.Axes {
+		next := make([]map[string]interface{}, 0, len(combinations)*len(axis.Values))
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				c := make(map[string]interface{}, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+
+				c[axis.Name] = value
+				next = append(next, c)
+			}
+		}
+
+		combinations = next
+	}
+
+	kept := combinations[:0]
+	for _, combination := range combinations {
+		excluded := false
+		for _, exclude := range m.Exclude {
+			if matrixMatches(combination, exclude) {
+				excluded = true
+				break
+			}
+		}
+
+		if !excluded {
+			kept = append(kept, combination)
+		}
+	}
+
+	combinations = kept
+	original := len(combinations)
+	for _, include := range m.Include {
+		matched := false
+		for _, combination := range combinations[:original] {
+			if !m.includes(combination, include) {
+				continue
+			}
+
+			matched = true
+			for k, v := range include {
+				combination[k] = v
+			}
+		}
+
+		if !matched {
+			c := make(map[string]interface{}, len(include))
+			for k, v := range include {
+				c[k] = v
+			}
+
+			combinations = append(combinations, c)
+		}
+	}
+
+	return combinations
+}
+
+// includes reports whether include can be added to combination without
+// changing any of the combination's axis values.
+func (m *Matrix) includes(combination, include map[string]interface{}) bool {
+	for _, axis := range m.Axes {
+		v, ok := include[axis.Name]
+		if ok && !reflect.DeepEqual(v, combination[axis.Name]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matrixMatches(combination, filter map[string]interface{}) bool {
+	for k, v := range filter {
+		if !reflect.DeepEqual(v, combination[k]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// identity lists the values an instance is named after: its axis values, or
+// for combinations added by Include without any, all of its values.
+func (m *Matrix) identity(values map[string]interface{}) []string {
+	keys := []string{}
+	for _, axis := range m.Axes {
+		if _, ok := values[axis.Name]; ok {
+			keys = append(keys, axis.Name)
+		}
+	}
+
+	if len(keys) == 0 {
+		keys = sortedKeys(values)
+	}
+
+	return keys
+}
+
+// ExpandMatrices replaces every task with a matrix by its instances, in the
+// parent's position. Instances keep the parent's needs, and tasks that need
+// the parent need every instance instead.
+func (o *TaskMap) ExpandMatrices() error {
+	instances := map[string][]string{}
+	tasks := make(map[string]*Task, len(o.tasks))
+	order := make([]string, 0, len(o.order))
+	for _, id := range o.order {
+		task := o.tasks[id]
+		if task.Matrix == nil {
+			tasks[id] = task
+			order = append(order, id)
+			continue
+		}
+
+		combinations := task.Matrix.Combinations()
+		if len(combinations) == 0 {
+			return fmt.Errorf("matrix for task %s has no combinations", id)
+		}
+
+		for _, values := range combinations {
+			instance := task.instance(values)
+			if _, ok := tasks[instance.Id]; ok || o.Has(instance.Id) {
+				return fmt.Errorf("matrix for task %s produces duplicate task %s", id, instance.Id)
+			}
+
+			tasks[instance.Id] = instance
+			order = append(order, instance.Id)
+			instances[id] = append(instances[id], instance.Id)
+		}
+	}
+
+	for _, id := range order {
+		task := tasks[id]
+		needs := make([]string, 0, len(task.Needs))
+		for _, need := range task.Needs {
+			ids, ok := instances[need]
+			if !ok {
+				needs = append(needs, need)
+				continue
+			}
+
+			needs = append(needs, ids...)
+			if loc, ok := task.needsAt[need]; ok {
+				delete(task.needsAt, need)
+				for _, instanceId := range ids {
+					task.needsAt[instanceId] = loc
+				}
+			}
+		}
+
+		task.Needs = needs
+	}
+
+	o.tasks = tasks
+	o.order = order
+	return nil
+}
+
+// instance returns a copy of the task for one matrix combination, with
+// expressions that are evaluated separately from the parent's.
+func (t *Task) instance(values map[string]interface{}) *Task {
+	keys := t.Matrix.identity(values)
+	pairs := make([]string, len(keys))
+	shown := make([]string, len(keys))
+	for i, key := range keys {
+		shown[i] = expr.ToString(values[key])
+		pairs[i] = key + "=" + shown[i]
+	}
+
+	// instances are named like test[go=1.22,os=linux].
+	instance := t.copy()
+	instance.Id = t.Id + "[" + strings.Join(pairs, ",") + "]"
+	instance.Matrix = nil
+	instance.MatrixOf = t.Id
+	instance.MatrixValues = values
+	instance.maxParallel = t.Matrix.MaxParallel
+	if t.Name != "" {
+		instance.Name = fmt.Sprintf("%s (%s)", t.Name, strings.Join(shown, ", "))
+	}
+
+	return instance
+}
+
+// copy returns the task with copies of its expressions, which can be
+// evaluated without changing the task's own.
+func (t *Task) copy() *Task {
+	c := *t
+	c.Needs = append([]string{}, t.Needs...)
+	c.needsAt = make(map[string]Location, len(t.needsAt))
+	for k, v := range t.needsAt {
+		c.needsAt[k] = v
+	}
+
+	if t.With != nil {
+		c.With = make(map[string]*expr.AnyExpression, len(t.With))
+		for k, v := range t.With {
+			c.With[k] = &expr.AnyExpression{Typed: v.Copy()}
+		}
+	}
+
+	if t.Env != nil {
+		c.Env = make(map[string]*expr.StringExpression, len(t.Env))
+		for k, v := range t.Env {
+			c.Env[k] = copyString(v)
+		}
+	}
+
+	c.Timeout = copyString(t.Timeout)
+	c.Cwd = copyString(t.Cwd)
+	c.RunExpr = copyString(t.RunExpr)
+	c.RetryDelay = copyString(t.RetryDelay)
+	c.Force = copyBool(t.Force)
+	c.If = copyBool(t.If)
+	c.ContinueOnError = copyBool(t.ContinueOnError)
+	if t.Retries != nil {
+		c.Retries = &expr.Uint32Expression{Typed: t.Retries.Copy()}
+	}
+
+	if t.RetryOn != nil {
+		c.RetryOn = &RetryOn{ExitCodes: t.RetryOn.ExitCodes, Condition: copyBool(t.RetryOn.Condition)}
+	}
+
+	return &c
+}
+
+func copyString(e *expr.StringExpression) *expr.StringExpression {
+	if e == nil {
+		return nil
+	}
+
+	return &expr.StringExpression{Typed: e.Copy()}
+}
+
+func copyBool(e *expr.BoolExpression) *expr.BoolExpression {
+	if e == nil {
+		return nil
+	}
+
+	return &expr.BoolExpression{Typed: e.Copy()}
+}
+
+// parseMatrix reads a `matrix` mapping of axes, plus the `include`, `exclude`
+// and `max-parallel` keys.
+func parseMatrix(node *yaml.Node) (*Matrix, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("matrix must be a mapping on line %d at column %d", node.Line, node.Column)
+	}
+
+	m := &Matrix{}
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+		key := keyNode.Value
+
+		switch key {
+		case "include", "exclude":
+			var entries []map[string]interface{}
+			if valueNode.Kind != yaml.SequenceNode || valueNode.Decode(&entries) != nil {
+				return nil, fmt.Errorf("matrix %s must be a sequence of mappings on line %d at column %d", key, valueNode.Line, valueNode.Column)
+			}
+
+			if key == "include" {
+				m.Include = entries
+			} else {
+				m.Exclude = entries
+			}
+
+		case "max-parallel":
+			var n int
+			if valueNode.Kind != yaml.ScalarNode || valueNode.Decode(&n) != nil || n < 0 {
+				return nil, fmt.Errorf("matrix max-parallel must be a non-negative integer on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			m.MaxParallel = n
+
+		default:
+			var values []interface{}
+			if valueNode.Kind != yaml.SequenceNode || valueNode.Decode(&values) != nil || len(values) == 0 {
+				return nil, fmt.Errorf("matrix %s must be a non-empty sequence on line %d at column %d", key, valueNode.Line, valueNode.Column)
+			}
+
+			m.Axes = append(m.Axes, MatrixAxis{Name: key, Values: values})
+		}
+	}
+
+	if len(m.Axes) == 0 && len(m.Include) == 0 {
+		return nil, fmt.Errorf("matrix must have at least one axis or include on line %d at column %d", node.Line, node.Column)
+	}
+
+	return m, nil
+}
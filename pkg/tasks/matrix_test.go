@@ -0,0 +1,186 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix Matrix
+		want   []map[string]interface{}
+	}{
+		{
+			name: "cartesian product",
+			matrix: Matrix{Axes: []MatrixAxis{
+				{Name: "os", Values: []interface{}{"linux", "windows"}},
+				{Name: "go", Values: []interface{}{"1.22", "1.23"}},
+			}},
+			want: []map[string]interface{}{
+				{"os": "linux", "go": "1.22"},
+				{"os": "linux", "go": "1.23"},
+				{"os": "windows", "go": "1.22"},
+				{"os": "windows", "go": "1.23"},
+			},
+		},
+		{
+			name: "exclude",
+			matrix: Matrix{
+				Axes: []MatrixAxis{
+					{Name: "os", Values: []interface{}{"linux", "windows"}},
+					{Name: "go", Values: []interface{}{"1.22", "1.23"}},
+				},
+				Exclude: []map[string]interface{}{{"os": "windows", "go": "1.22"}},
+			},
+			want: []map[string]interface{}{
+				{"os": "linux", "go": "1.22"},
+				{"os": "linux", "go": "1.23"},
+				{"os": "windows", "go": "1.23"},
+			},
+		},
+		{
+			name: "include extends matching combinations",
+			matrix: Matrix{
+				Axes:    []MatrixAxis{{Name: "os", Values: []interface{}{"linux", "windows"}}},
+				Include: []map[string]interface{}{{"os": "windows", "shell": "pwsh"}},
+			},
+			want: []map[string]interface{}{
+				{"os": "linux"},
+				{"os": "windows", "shell": "pwsh"},
+			},
+		},
+		{
+			name: "include without a match adds a combination",
+			matrix: Matrix{
+				Axes:    []MatrixAxis{{Name: "os", Values: []interface{}{"linux"}}},
+				Include: []map[string]interface{}{{"os": "darwin", "arch": "arm64"}},
+			},
+			want: []map[string]interface{}{
+				{"os": "linux"},
+				{"os": "darwin", "arch": "arm64"},
+			},
+		},
+		{
+			name: "include only",
+			matrix: Matrix{
+				Include: []map[string]interface{}{{"os": "linux"}, {"os": "darwin"}},
+			},
+			want: []map[string]interface{}{
+				{"os": "linux"},
+				{"os": "darwin"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matrix.Combinations(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Combinations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandMatrices(t *testing.T) {
+	w := parseWorkflow(t, `
+tasks:
+  test:
+    name: Test
+    matrix:
+      os: [linux, windows]
+      go: ["1.22"]
+      max-parallel: 1
+    env:
+      OS: ${{ matrix.os }}
+  deploy:
+    needs: [test]
+`)
+
+	wantIds := []string{"test[os=linux,go=1.22]", "test[os=windows,go=1.22]", "deploy"}
+	if got := w.Tasks.Keys(); !reflect.DeepEqual(got, wantIds) {
+		t.Fatalf("tasks = %v, want %v", got, wantIds)
+	}
+
+	instance := w.Tasks.Get(wantIds[1])
+	if instance.Name != "Test (windows, 1.22)" || instance.MatrixOf != "test" || instance.maxParallel != 1 {
+		t.Errorf("instance = name %q, matrix of %q, max-parallel %d", instance.Name, instance.MatrixOf, instance.maxParallel)
+	}
+
+	if got := w.Tasks.Get("deploy").Needs; !reflect.DeepEqual(got, wantIds[:2]) {
+		t.Errorf("deploy needs %v, want %v", got, wantIds[:2])
+	}
+
+	// instances evaluate their own copies of the parent's expressions.
+	for i, os := range []string{"linux", "windows"} {
+		ctx := &TaskContext{Context: primitives.Context{Outputs: &primitives.ObjectMap{}, Vars: &primitives.ObjectMap{}}}
+		if err := w.Tasks.Get(wantIds[i]).Eval(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ctx.State.Env["OS"]; got != os {
+			t.Errorf("%s has OS %q, want %q", wantIds[i], got, os)
+		}
+	}
+}
+
+func TestInferDependenciesOnMatrixTasks(t *testing.T) {
+	const src = `
+tasks:
+  build:
+    matrix:
+      os: [linux, windows]
+  deploy:
+    env:
+      A: ${{ outputs.build.artifact }}
+  self:
+    matrix:
+      n: [1]
+    env:
+      B: ${{ outputs.self.b }}
+`
+
+	want := []string{"build[os=linux]", "build[os=windows]"}
+	w := parseWorkflow(t, src)
+	if got := w.Tasks.Get("deploy").Needs; !reflect.DeepEqual(got, want) {
+		t.Errorf("deploy needs %v, want %v", got, want)
+	}
+
+	if len(w.Warnings) != 1 || w.Warnings[0].Message != "a task cannot read its own outputs" {
+		t.Errorf("warnings = %v, want one for self", w.Warnings)
+	}
+
+	// the same holds when the matrices were expanded before inferring.
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := &TaskMap{}
+	mapping := node.Content[0].Content[1]
+	for i := 0; i < len(mapping.Content); i += 2 {
+		task := &Task{}
+		if err := mapping.Content[i+1].Decode(task); err != nil {
+			t.Fatal(err)
+		}
+
+		task.Id = mapping.Content[i].Value
+		tasks.Add(task.Id, task)
+	}
+
+	if err := tasks.ExpandMatrices(); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := tasks.InferDependencies()
+	if got := tasks.Get("deploy").Needs; !reflect.DeepEqual(got, want) {
+		t.Errorf("after expanding, deploy needs %v, want %v", got, want)
+	}
+
+	if len(warnings) != 1 || warnings[0].Task != "self[n=1]" {
+		t.Errorf("after expanding, warnings = %v, want one for self[n=1]", warnings)
+	}
+}
@@ -0,0 +1,189 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+const (
+	// OutputEnv names the environment variable holding the file a task writes
+	// its outputs to.
+	OutputEnv = "J9_OUTPUT"
+
+	// EnvFileEnv names the file a task writes environment variables to for
+	// the tasks that run after it.
+	EnvFileEnv = "J9_ENV"
+
+	// PathFileEnv names the file a task writes directories to, which are put
+	// in front of PATH for the tasks that run after it.
+	PathFileEnv = "J9_PATH"
+)
+
+// ParseOutputFile reads a J9_OUTPUT file. Each entry is either `key=value`
+// on one line or, for multiline values, a heredoc:
+//
+//	key<<DELIMITER
+//	first line
+//	second line
+//	DELIMITER
+//
+// Blank lines between entries are ignored and a later entry for the same
+// key replaces an earlier one.
+func ParseOutputFile(path string) (*primitives.ObjectMap, error) {
+	outputs := &primitives.ObjectMap{}
+	err := parseEntries(path, OutputEnv, func(key, value string) error {
+		outputs.Set(key, value)
+		return nil
+	})
+
+	return outputs, err
+}
+
+// ParseEnvFile reads a J9_ENV file, which has the same entries as a
+// J9_OUTPUT file. The J9_* files themselves cannot be replaced.
+func ParseEnvFile(path string) (map[string]string, error) {
+	env := map[string]string{}
+	err := parseEntries(path, EnvFileEnv, func(key, value string) error {
+		switch key {
+		case OutputEnv, EnvFileEnv, PathFileEnv:
+			return fmt.Errorf("%s cannot set %s", EnvFileEnv, key)
+		}
+
+		env[key] = value
+		return nil
+	})
+
+	return env, err
+}
+
+// ParsePathFile reads a J9_PATH file: one directory per line, in the order
+// they were added. Blank lines are ignored.
+func ParsePathFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+
+	return dirs, nil
+}
+
+// parseEntries calls set for each key=value or heredoc entry of the file
+// named by the environment variable name.
+func parseEntries(path, name string, set func(key, value string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if key, delimiter, ok := strings.Cut(text, "<<"); ok && !strings.Contains(key, "=") {
+			key = strings.TrimSpace(key)
+			delimiter = strings.TrimSpace(delimiter)
+			if key == "" || delimiter == "" {
+				return fmt.Errorf("%s entry on line %d must be key<<DELIMITER", name, line)
+			}
+
+			start := line
+			lines := []string{}
+			closed := false
+			for scanner.Scan() {
+				line++
+				value := strings.TrimSuffix(scanner.Text(), "\r")
+				if value == delimiter {
+					closed = true
+					break
+				}
+
+				lines = append(lines, value)
+			}
+
+			if !closed {
+				return fmt.Errorf("%s entry %s on line %d is missing its closing %s", name, key, start, delimiter)
+			}
+
+			if err := set(key, strings.Join(lines, "\n")); err != nil {
+				return fmt.Errorf("%s on line %d", err, start)
+			}
+
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s entry on line %d must be key=value or key<<DELIMITER", name, line)
+		}
+
+		if err := set(key, value); err != nil {
+			return fmt.Errorf("%s on line %d", err, line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// validateOutputs checks outputs against the descriptor, converting each to
+// its declared type and masking secret ones. Without a descriptor, or one
+// that declares no outputs, anything is accepted.
+func validateOutputs(ctx *TaskContext, outputs *primitives.ObjectMap) error {
+	if ctx.Descriptor == nil || len(ctx.Descriptor.Outputs) == 0 {
+		return nil
+	}
+
+	id := ctx.State.Id
+	declared := ctx.Descriptor.Outputs
+	for _, key := range outputs.Keys() {
+		out, ok := declared[key]
+		if !ok {
+			return fmt.Errorf("output %s is not defined for task %s", key, id)
+		}
+
+		value, err := coerceDeclared(outputs.Get(key), out.Type)
+		if err != nil {
+			return fmt.Errorf("output %s must be a valid %s for task %s: %w", key, inputTypeName(out.Type), id, err)
+		}
+
+		if out.IsSecret {
+			ctx.State.AddMask(outputs.GetString(key))
+		}
+
+		outputs.Set(key, value)
+	}
+
+	keys := make([]string, 0, len(declared))
+	for key := range declared {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if declared[key].IsRequired && !outputs.Has(key) {
+			return fmt.Errorf("output %s is required for task %s", key, id)
+		}
+	}
+
+	return nil
+}
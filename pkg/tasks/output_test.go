@@ -0,0 +1,165 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestParseOutputFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "key=value",
+			content: "a=1\nb=x=y\nc=\n",
+			want:    map[string]string{"a": "1", "b": "x=y", "c": ""},
+		},
+		{
+			name:    "heredoc",
+			content: "notes<<EOF\nfirst\n\nsecond\nEOF\nafter=1\n",
+			want:    map[string]string{"notes": "first\n\nsecond", "after": "1"},
+		},
+		{
+			name:    "empty heredoc",
+			content: "empty<<EOF\nEOF\n",
+			want:    map[string]string{"empty": ""},
+		},
+		{
+			name:    "delimiter inside the value",
+			content: "text<<EOF\nno EOF here\nEOF \n  EOF\nEOFX\nEOF\n",
+			want:    map[string]string{"text": "no EOF here\nEOF \n  EOF\nEOFX"},
+		},
+		{
+			name:    "<< inside a value",
+			content: "cmd=cat <<EOF\n",
+			want:    map[string]string{"cmd": "cat <<EOF"},
+		},
+		{
+			name:    "CRLF",
+			content: "a=1\r\ntext<<EOF\r\nline one\r\nline two\r\nEOF\r\nb=2\r\n",
+			want:    map[string]string{"a": "1", "text": "line one\nline two", "b": "2"},
+		},
+		{
+			name:    "blank lines and later entries win",
+			content: "\n  \na=1\n\na=2\n",
+			want:    map[string]string{"a": "2"},
+		},
+		{
+			name:    "missing closing delimiter",
+			content: "a=1\ntext<<EOF\nline\nEOFX\n",
+			err:     "entry text on line 2 is missing its closing EOF",
+		},
+		{
+			name:    "missing delimiter",
+			content: "text<<\nEOF\n",
+			err:     "on line 1 must be key<<DELIMITER",
+		},
+		{
+			name:    "missing =",
+			content: "a=1\njust text\n",
+			err:     "on line 2 must be key=value or key<<DELIMITER",
+		},
+		{
+			name:    "missing key",
+			content: "=1\n",
+			err:     "on line 1 must be key=value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs, err := ParseOutputFile(writeFile(t, tt.content))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			for _, key := range outputs.Keys() {
+				got[key] = outputs.GetString(key)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "entries",
+			content: "A=1\n\n   \nB=two words\nC<<EOF\nx\ny\nEOF\n",
+			want:    map[string]string{"A": "1", "B": "two words", "C": "x\ny"},
+		},
+		{
+			name:    "duplicate keys keep the last value",
+			content: "A=1\nB=1\nA=2\n",
+			want:    map[string]string{"A": "2", "B": "1"},
+		},
+		{
+			name:    "J9 files cannot be replaced",
+			content: "A=1\nJ9_OUTPUT=/tmp/other\n",
+			err:     "J9_ENV cannot set J9_OUTPUT on line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := ParseEnvFile(writeFile(t, tt.content))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(env, tt.want) {
+				t.Errorf("got %q, want %q", env, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePathFile(t *testing.T) {
+	dirs, err := ParsePathFile(writeFile(t, "/opt/a\n\n  /opt/b  \r\n/opt/a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"/opt/a", "/opt/b", "/opt/a"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("got %q, want %q", dirs, want)
+	}
+}
@@ -1,6 +1,13 @@
 package tasks
 
-import "github.com/jolt9dev/go-jolt9/pkg/primitives"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+	"gopkg.in/yaml.v3"
+)
 
 type TaskDescriptor struct {
 	Id          string                                 `json:"id" yaml:"id"`
@@ -11,6 +18,41 @@ type TaskDescriptor struct {
 	Redirect    bool                                   `json:"redirect,omitempty" yaml:"redirect,omitempty"`
 	RunFile     string                                 `json:"run,omitempty" yaml:"run,omitempty"`
 	Uses        string                                 `json:"uses,omitempty" yaml:"uses,omitempty"`
+
+	// Dir is the directory the descriptor was loaded from, which RunFile is
+	// relative to.
+	Dir string `json:"-" yaml:"-"`
+}
+
+// LoadTaskDescriptor reads a descriptor file.
+func LoadTaskDescriptor(path string) (*TaskDescriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor := &TaskDescriptor{}
+	if err := yaml.Unmarshal(data, descriptor); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor.Dir = dir
+	return descriptor, nil
+}
+
+// RunPath returns the script the descriptor runs, resolved against Dir, or
+// an empty string when it has none.
+func (d *TaskDescriptor) RunPath() string {
+	if d.RunFile == "" || filepath.IsAbs(d.RunFile) {
+		return d.RunFile
+	}
+
+	return filepath.Join(d.Dir, d.RunFile)
 }
 
 type TaskRegistry struct {
@@ -27,6 +69,17 @@ func (r *TaskRegistry) Register(task *TaskDescriptor) {
 	r.tasks[task.Id] = task
 }
 
+// Load reads a descriptor file and registers it.
+func (r *TaskRegistry) Load(path string) (*TaskDescriptor, error) {
+	descriptor, err := LoadTaskDescriptor(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Register(descriptor)
+	return descriptor, nil
+}
+
 func (r *TaskRegistry) Get(id string) (*TaskDescriptor, bool) {
 	task, ok := r.tasks[id]
 	return task, ok
@@ -0,0 +1,145 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+)
+
+// RetryBackoff is how the delay between attempts grows.
+type RetryBackoff string
+
+const (
+	BackoffConstant    RetryBackoff = "constant"
+	BackoffLinear      RetryBackoff = "linear"
+	BackoffExponential RetryBackoff = "exponential"
+)
+
+func ParseRetryBackoff(s string) (RetryBackoff, error) {
+	switch b := RetryBackoff(s); b {
+	case "":
+		return BackoffConstant, nil
+	case BackoffConstant, BackoffLinear, BackoffExponential:
+		return b, nil
+	}
+
+	return "", fmt.Errorf("unknown retry backoff %q", s)
+}
+
+// Delay returns how long to wait before the given retry, counting from 1.
+// Exponential delays double with every retry and are jittered to between half
+// and all of that value, so that tasks failing together do not retry in step.
+func (b RetryBackoff) Delay(base time.Duration, retry int) time.Duration {
+	if base <= 0 || retry < 1 {
+		return 0
+	}
+
+	switch b {
+	case BackoffLinear:
+		return base * time.Duration(retry)
+	case BackoffExponential:
+		d := base
+		for i := 1; i < retry && d <= math.MaxInt64/2; i++ {
+			d *= 2
+		}
+
+		half := d / 2
+		return half + rand.N(d-half+1)
+	}
+
+	return base
+}
+
+// RetryOn limits which failed attempts are retried: those that exited with
+// one of ExitCodes, or those for which Condition is true. Condition sees the
+// failed attempt as `attempt.number`, `attempt.exitCode` and `attempt.error`.
+// A task without RetryOn retries every failure.
+type RetryOn struct {
+	ExitCodes []int
+	Condition *expr.BoolExpression
+}
+
+// TaskAttempt records one run of a task's delegate. ExitCode is 0 when the
+// attempt succeeded and -1 when the error did not carry an exit code.
+type TaskAttempt struct {
+	Number     int
+	Status     TaskStatus
+	ExitCode   int
+	Error      error
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// ExitCoder is implemented by errors that carry the exit code of a process,
+// such as *exec.ExitError.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+
+	return -1
+}
+
+// ShouldRetry reports whether a failed attempt may be retried according to
+// the task's `retry-on`. It does not check the number of retries left.
+func (t *Task) ShouldRetry(ctx *TaskContext, attempt TaskAttempt) (bool, error) {
+	if t.RetryOn == nil {
+		return true, nil
+	}
+
+	for _, code := range t.RetryOn.ExitCodes {
+		if code == attempt.ExitCode {
+			return true, nil
+		}
+	}
+
+	condition := t.RetryOn.Condition
+	if condition == nil {
+		return false, nil
+	}
+
+	evaluator := ctx.Evaluator
+	if evaluator == nil {
+		evaluator = t.Dialect()
+	}
+
+	if c, ok := evaluator.(expr.Cancellable); ok && ctx.Signal != nil {
+		evaluator = c.WithSignal(ctx.Signal)
+	}
+
+	cwd := ""
+	if ctx.State != nil {
+		cwd = ctx.State.Cwd
+	}
+
+	message := ""
+	if attempt.Error != nil {
+		message = attempt.Error.Error()
+	}
+
+	data := t.expressionData(ctx, cwd)
+	data["attempt"] = map[string]interface{}{
+		"number":   attempt.Number,
+		"exitCode": attempt.ExitCode,
+		"error":    message,
+	}
+
+	if err := condition.Eval(evaluator, data); err != nil {
+		return false, fmt.Errorf("retry-on for task %s: %w", t.Id, err)
+	}
+
+	return condition.Bool(), nil
+}
@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+// ScriptShell chooses how to run a script file: by its shebang line when it
+// has one, otherwise by its extension. .sh and .bash run with bash (.sh
+// falls back to sh), .py with python, .ps1 with pwsh, .js with deno, or node
+// where deno is missing, .ts with deno and .go with `go run`.
+func ScriptShell(path string) (Shell, error) {
+	if command, ok := shebang(path); ok {
+		return Shell{Command: append(command, "{0}")}, nil
+	}
+
+	var name string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".sh":
+		name = "bash"
+		if _, err := exec.LookPath("bash"); err != nil {
+			name = "sh"
+		}
+	case ".bash":
+		name = "bash"
+	case ".py":
+		name = "python"
+	case ".ps1":
+		name = "pwsh"
+		if _, err := exec.LookPath("pwsh"); err != nil {
+			name = "powershell"
+		}
+	case ".js", ".mjs", ".ts":
+		if _, err := exec.LookPath("deno"); err == nil {
+			return Shell{Command: []string{"deno", "run", "--allow-all", "{0}"}}, nil
+		}
+
+		// node only strips types behind a flag that older versions reject.
+		if ext == ".ts" {
+			return Shell{}, fmt.Errorf("cannot run %s: .ts scripts need deno, which is not installed", path)
+		}
+
+		return Shell{Command: []string{"node", "{0}"}}, nil
+	case ".go":
+		return Shell{Command: []string{"go", "run", "{0}"}}, nil
+	default:
+		return Shell{}, fmt.Errorf("cannot tell how to run %s: it has no shebang and an unknown extension %q", path, ext)
+	}
+
+	shell, err := ParseShell(name)
+	if err != nil {
+		return Shell{}, err
+	}
+
+	// the prelude and epilogue only apply to scripts written for `run`.
+	shell.Prelude = ""
+	shell.Epilogue = ""
+	return shell, nil
+}
+
+// shebang returns the interpreter named on the script's `#!` line. An
+// `/usr/bin/env` prefix is dropped so that the interpreter is looked up on
+// PATH, which also lets such scripts run on Windows.
+func shebang(path string) ([]string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, false
+	}
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#!") {
+		return nil, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) > 0 && filepath.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		if len(fields) > 0 && fields[0] == "-S" {
+			fields = fields[1:]
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	if _, err := os.Stat(fields[0]); err != nil {
+		fields[0] = filepath.Base(fields[0])
+	}
+
+	return fields, true
+}
+
+// ScriptTask runs the script file of a task descriptor. Inputs reach the
+// script as INPUT_* environment variables.
+type ScriptTask struct {
+	Path string
+}
+
+func (s *ScriptTask) Run(ctx TaskContext) (primitives.ObjectMap, error) {
+	outputs := primitives.ObjectMap{}
+	shell, err := ScriptShell(s.Path)
+	if err != nil {
+		return outputs, err
+	}
+
+	if err := shell.run(ctx, s.Path, &outputs); err != nil {
+		return outputs, fmt.Errorf("%s for task %s failed: %w", filepath.Base(s.Path), ctx.State.Id, err)
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,162 @@
+package tasks
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+// fakePath replaces PATH with a directory holding empty executables named
+// programs, so that lookups find exactly those.
+func fakePath(t *testing.T, programs ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range programs {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("PATH", dir)
+}
+
+func TestScriptShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake programs on PATH need an executable bit")
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		script   string
+		programs []string
+		want     []string
+		err      bool
+	}{
+		{name: "shebang", file: "run", script: "#!/bin/sh -e\necho hi\n", want: []string{"/bin/sh", "-e", "{0}"}},
+		{name: "shebang missing interpreter", file: "run.sh", script: "#!/opt/nowhere/ruby -w\n", want: []string{"ruby", "-w", "{0}"}},
+		{name: "shebang env", file: "run.py", script: "#!/usr/bin/env python3\n", want: []string{"python3", "{0}"}},
+		{name: "shebang env -S", file: "run", script: "#!/usr/bin/env -S node --no-warnings\n", want: []string{"node", "--no-warnings", "{0}"}},
+		{name: "shebang env without program", file: "run.sh", script: "#!/usr/bin/env\n", programs: []string{"bash"}, want: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", "{0}"}},
+		{name: "sh", file: "run.sh", programs: []string{"bash", "sh"}, want: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", "{0}"}},
+		{name: "sh without bash", file: "run.sh", programs: []string{"sh"}, want: []string{"sh", "-e", "{0}"}},
+		{name: "bash", file: "run.bash", want: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", "{0}"}},
+		{name: "python", file: "run.py", programs: []string{"python3"}, want: []string{"python3", "{0}"}},
+		{name: "python without python3", file: "run.py", want: []string{"python", "{0}"}},
+		{name: "pwsh", file: "run.ps1", programs: []string{"pwsh"}, want: []string{"pwsh", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", ". '{0}'"}},
+		{name: "powershell", file: "run.PS1", want: []string{"powershell", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", ". '{0}'"}},
+		{name: "js with deno", file: "run.js", programs: []string{"deno", "node"}, want: []string{"deno", "run", "--allow-all", "{0}"}},
+		{name: "js without deno", file: "run.mjs", programs: []string{"node"}, want: []string{"node", "{0}"}},
+		{name: "ts with deno", file: "run.ts", programs: []string{"deno"}, want: []string{"deno", "run", "--allow-all", "{0}"}},
+		{name: "ts without deno", file: "run.ts", programs: []string{"node"}, err: true},
+		{name: "go", file: "main.go", want: []string{"go", "run", "{0}"}},
+		{name: "unknown extension", file: "run.rb", err: true},
+		{name: "no extension", file: "run", script: "echo hi\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			fakePath(t, tt.programs...)
+			shell, err := ScriptShell(path)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %q, want an error", shell.Command)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(shell.Command, tt.want) {
+				t.Errorf("got %q, want %q", shell.Command, tt.want)
+			}
+
+			if shell.Prelude != "" || shell.Epilogue != "" {
+				t.Errorf("got prelude %q and epilogue %q, want none", shell.Prelude, shell.Epilogue)
+			}
+		})
+	}
+}
+
+func TestTaskDescriptorRunPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "greet")
+	abs := filepath.Join(t.TempDir(), "run.sh")
+	tests := []struct {
+		run  string
+		want string
+	}{
+		{"", ""},
+		{"run.sh", filepath.Join(dir, "run.sh")},
+		{filepath.Join("bin", "run.sh"), filepath.Join(dir, "bin", "run.sh")},
+		{abs, abs},
+	}
+
+	for _, tt := range tests {
+		d := &TaskDescriptor{Dir: dir, RunFile: tt.run}
+		if got := d.RunPath(); got != tt.want {
+			t.Errorf("RunPath() with run %q = %q, want %q", tt.run, got, tt.want)
+		}
+	}
+}
+
+func TestScriptTask(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	dir := t.TempDir()
+	script := "#!/usr/bin/env sh\necho \"hello $INPUT_NAME\"\necho \"::set-output name=greeting::hi $INPUT_LOUD_NAME\"\n"
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bin", "greet"), []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewTaskRegistry()
+	registry.Register(&TaskDescriptor{
+		Id: "greet",
+		Inputs: map[string]primitives.InputDescriptor{
+			"name":      {Name: "name", IsRequired: true},
+			"loud-name": {Name: "loud-name"},
+		},
+		RunFile: filepath.Join("bin", "greet"),
+		Dir:     dir,
+	})
+
+	w := parseWorkflow(t, `
+tasks:
+  a:
+    uses: greet
+    with:
+      name: world
+      loud-name: WORLD
+`)
+
+	bus := &messageBus{}
+	results, err := w.NewExecutor(registry, DefaultResolver).Run(primitives.Context{Workspace: dir, Bus: bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bus.lines("stdout"); !reflect.DeepEqual(got, []string{"a: hello world"}) {
+		t.Errorf("got stdout %q, want [\"a: hello world\"]", got)
+	}
+
+	if got := results[0].Outputs.GetString("greeting"); got != "hi WORLD" {
+		t.Errorf("got greeting %q, want \"hi WORLD\"", got)
+	}
+}
@@ -0,0 +1,297 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+	"github.com/jolt9dev/go-jolt9/pkg/process"
+)
+
+// Shell describes how a `run` script is executed. Command is the command
+// line with `{0}` standing for the script file, which is written with
+// Extension and has Prelude and Epilogue put around the script.
+type Shell struct {
+	Command   []string
+	Extension string
+	Prelude   string
+	Epilogue  string
+}
+
+var shells = map[string]Shell{
+	"bash": {Command: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", "{0}"}, Extension: ".sh"},
+	"sh":   {Command: []string{"sh", "-e", "{0}"}, Extension: ".sh"},
+	"pwsh": {
+		Command:   []string{"pwsh", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", ". '{0}'"},
+		Extension: ".ps1",
+		Prelude:   "$ErrorActionPreference = 'stop'\n",
+		Epilogue:  "\nif ((Test-Path -LiteralPath variable:\\LASTEXITCODE)) { exit $LASTEXITCODE }\n",
+	},
+	"powershell": {
+		Command:   []string{"powershell", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", ". '{0}'"},
+		Extension: ".ps1",
+		Prelude:   "$ErrorActionPreference = 'stop'\n",
+		Epilogue:  "\nif ((Test-Path -LiteralPath variable:\\LASTEXITCODE)) { exit $LASTEXITCODE }\n",
+	},
+	"python": {Command: []string{"python", "{0}"}, Extension: ".py"},
+}
+
+// ParseShell returns the shell for a task's `shell` key: bash, sh, pwsh,
+// powershell, python, or a command line such as `bash -e {0}`, which is split
+// on whitespace without regard for quotes. An empty name selects pwsh on
+// Windows and bash, or sh where bash is missing, elsewhere.
+func ParseShell(name string) (Shell, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "bash"
+		if runtime.GOOS == "windows" {
+			name = "pwsh"
+		} else if _, err := exec.LookPath("bash"); err != nil {
+			name = "sh"
+		}
+	}
+
+	if shell, ok := shells[name]; ok {
+		if name == "python" {
+			if _, err := exec.LookPath("python3"); err == nil {
+				shell.Command = []string{"python3", "{0}"}
+			}
+		}
+
+		return shell, nil
+	}
+
+	command := strings.Fields(name)
+	if !strings.Contains(name, "{0}") {
+		return Shell{}, fmt.Errorf("shell %q must be one of bash, sh, pwsh, powershell or python, or contain {0} for the script file", name)
+	}
+
+	// a custom command line still gets the extension its program expects.
+	shell := Shell{Command: command}
+	program := strings.TrimSuffix(filepath.Base(command[0]), ".exe")
+	if known, ok := shells[program]; ok {
+		shell.Extension = known.Extension
+	}
+
+	return shell, nil
+}
+
+// OutputMessage is a line written by a task to its stdout or stderr, with
+// the task's masks applied.
+type OutputMessage struct {
+	Id     string
+	Stream string
+	Text   string
+}
+
+func (m *OutputMessage) Kind() string {
+	return "task.output"
+}
+
+// ShellTask runs the task's `run` script with Shell.
+type ShellTask struct {
+	Shell Shell
+}
+
+func (s *ShellTask) Run(ctx TaskContext) (primitives.ObjectMap, error) {
+	outputs := primitives.ObjectMap{}
+	state := ctx.State
+
+	file, err := os.CreateTemp("", "j9-*"+s.Shell.Extension)
+	if err != nil {
+		return outputs, err
+	}
+
+	defer os.Remove(file.Name())
+	script := s.Shell.Prelude + state.RunExpr + "\n" + s.Shell.Epilogue
+	if _, err := file.WriteString(script); err != nil {
+		file.Close()
+		return outputs, err
+	}
+
+	if err := file.Close(); err != nil {
+		return outputs, err
+	}
+
+	if err := s.Shell.run(ctx, file.Name(), &outputs); err != nil {
+		return outputs, fmt.Errorf("run for task %s failed: %w", state.Id, err)
+	}
+
+	return outputs, nil
+}
+
+// run executes script with the shell in the task's directory and
+// environment, streaming its output. Outputs set with ::set-output:: are
+// added to outputs.
+func (s Shell) run(ctx TaskContext, script string, outputs *primitives.ObjectMap) error {
+	args := make([]string, len(s.Command))
+	for i, arg := range s.Command {
+		args[i] = strings.ReplaceAll(arg, "{0}", script)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = taskDir(ctx)
+	cmd.Env = taskEnv(ctx)
+	cmd.WaitDelay = ctx.GracePeriod
+
+	mu := &sync.Mutex{}
+	stdout := &lineWriter{mu: mu, ctx: ctx, stream: "stdout", outputs: outputs}
+	stderr := &lineWriter{mu: mu, ctx: ctx, stream: "stderr"}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := process.Run(ctx.Signal, cmd, ctx.GracePeriod)
+	stdout.Flush()
+	stderr.Flush()
+	return err
+}
+
+// taskDir resolves the task's cwd against the workspace.
+func taskDir(ctx TaskContext) string {
+	dir := ctx.State.Cwd
+	if dir == "" || !filepath.IsAbs(dir) {
+		dir = filepath.Join(ctx.Workspace, dir)
+	}
+
+	if dir == "" {
+		return "."
+	}
+
+	return dir
+}
+
+// taskEnv is the process environment with the task's environment on top.
+// Names are case-insensitive on Windows, so that a PATH exported by an
+// earlier task replaces the process's Path.
+func taskEnv(ctx TaskContext) []string {
+	env := map[string]string{}
+	names := map[string]string{}
+	set := func(k, v string) {
+		if runtime.GOOS == "windows" {
+			folded := strings.ToUpper(k)
+			if name, ok := names[folded]; ok {
+				delete(env, name)
+			}
+
+			names[folded] = k
+		}
+
+		env[k] = v
+	}
+
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			set(k, v)
+		}
+	}
+
+	for k, v := range ctx.Env {
+		set(k, v)
+	}
+
+	for k, v := range ctx.State.Env {
+		set(k, v)
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k + "=" + env[k]
+	}
+
+	return result
+}
+
+// lineWriter sends each complete line written to it to the bus, or to the
+// matching standard stream when there is no bus. With outputs set, workflow
+// commands are carried out and published as their own messages instead. The
+// writers of one process share mu, as ::add-mask:: changes the masks both
+// apply.
+type lineWriter struct {
+	mu      *sync.Mutex
+	ctx     TaskContext
+	stream  string
+	outputs *primitives.ObjectMap
+	buf     []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.send(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush sends a last line that did not end with a newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.send(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) send(line string) {
+	if w.outputs != nil {
+		if cmd, ok := ParseWorkflowCommand(line); ok {
+			if msg, ok := command(w.ctx.State, w.outputs, cmd); ok {
+				if msg != nil {
+					w.publish(msg)
+				}
+
+				return
+			}
+		}
+	}
+
+	w.publish(&OutputMessage{Id: w.ctx.State.Id, Stream: w.stream, Text: w.ctx.State.Mask(line)})
+}
+
+// publish sends msg to the bus. Without one, output lines go to their stream
+// and other messages that print as text to stderr.
+func (w *lineWriter) publish(msg primitives.Message) {
+	if w.ctx.Bus != nil {
+		w.ctx.Bus.Send(msg)
+		return
+	}
+
+	switch m := msg.(type) {
+	case *OutputMessage:
+		var out io.Writer = os.Stdout
+		if m.Stream == "stderr" {
+			out = os.Stderr
+		}
+
+		fmt.Fprintln(out, m.Text)
+	case fmt.Stringer:
+		if text := m.String(); text != "" {
+			fmt.Fprintln(os.Stderr, text)
+		}
+	}
+}
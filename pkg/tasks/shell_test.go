@@ -0,0 +1,205 @@
+package tasks
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+// messageBus records the messages sent to it. Only Send is implemented.
+type messageBus struct {
+	primitives.LoggingMessageBus
+	mu       sync.Mutex
+	messages []primitives.Message
+}
+
+func (b *messageBus) Send(msg primitives.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// lines returns the output messages sent for stream as "id: text".
+func (b *messageBus) lines(stream string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := []string{}
+	for _, msg := range b.messages {
+		if m, ok := msg.(*OutputMessage); ok && m.Stream == stream {
+			lines = append(lines, m.Id+": "+m.Text)
+		}
+	}
+
+	return lines
+}
+
+func TestParseShell(t *testing.T) {
+	python := "python"
+	if _, err := exec.LookPath("python3"); err == nil {
+		python = "python3"
+	}
+
+	tests := []struct {
+		name      string
+		command   []string
+		extension string
+		err       bool
+	}{
+		{name: "bash", command: []string{"bash", "--noprofile", "--norc", "-eo", "pipefail", "{0}"}, extension: ".sh"},
+		{name: "sh", command: []string{"sh", "-e", "{0}"}, extension: ".sh"},
+		{name: "pwsh", command: []string{"pwsh", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", ". '{0}'"}, extension: ".ps1"},
+		{name: "powershell", command: []string{"powershell", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", ". '{0}'"}, extension: ".ps1"},
+		{name: "python", command: []string{python, "{0}"}, extension: ".py"},
+		{name: "bash -e {0}", command: []string{"bash", "-e", "{0}"}, extension: ".sh"},
+		{name: " /usr/bin/python -u {0} ", command: []string{"/usr/bin/python", "-u", "{0}"}, extension: ".py"},
+		{name: "perl {0}", command: []string{"perl", "{0}"}},
+		{name: "bash -e", err: true},
+		{name: "zsh", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell, err := ParseShell(tt.name)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseShell(%q) = %v, want an error", tt.name, shell)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(shell.Command, tt.command) || shell.Extension != tt.extension {
+				t.Errorf("got %q with extension %q, want %q with extension %q", shell.Command, shell.Extension, tt.command, tt.extension)
+			}
+		})
+	}
+}
+
+func TestShellTask(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	workspace := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workspace, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		run    string
+		env    map[string]string
+		cwd    string
+		code   int
+		stdout []string
+		stderr []string
+	}{
+		{
+			name:   "succeeds",
+			run:    "echo one\necho two",
+			stdout: []string{"a: one", "a: two"},
+		},
+		{
+			name:   "failing command mid-script",
+			run:    "echo before\n(exit 3)\necho after",
+			code:   3,
+			stdout: []string{"a: before"},
+		},
+		{
+			name:   "failing pipeline stage",
+			run:    "(exit 4) | cat\necho after",
+			code:   4,
+			stdout: []string{},
+		},
+		{
+			name:   "env and cwd",
+			run:    `echo "$GREETING from $(basename "$(pwd)")"`,
+			env:    map[string]string{"GREETING": "hello"},
+			cwd:    "sub",
+			stdout: []string{"a: hello from sub"},
+		},
+		{
+			name:   "absolute cwd",
+			run:    `pwd`,
+			cwd:    filepath.Join(workspace, "sub"),
+			stdout: []string{"a: " + filepath.Join(workspace, "sub")},
+		},
+		{
+			name:   "masks both streams",
+			run:    "echo token=s3cr3t\necho bad s3cr3t >&2\nprintf 'no newline'",
+			stdout: []string{"a: token=***", "a: no newline"},
+			stderr: []string{"a: bad ***"},
+		},
+	}
+
+	shell, err := ParseShell("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &messageBus{}
+			state := &TaskState{Id: "a", RunExpr: tt.run, Env: tt.env, Cwd: tt.cwd}
+			state.AddMask("s3cr3t")
+			ctx := TaskContext{
+				Context: primitives.Context{Signal: context.Background(), Workspace: workspace, Bus: bus},
+				State:   state,
+			}
+
+			_, err := (&ShellTask{Shell: shell}).Run(ctx)
+			if tt.code == 0 && err != nil {
+				t.Fatal(err)
+			}
+
+			if got := exitCode(err); got != tt.code {
+				t.Errorf("got exit code %d (%v), want %d", got, err, tt.code)
+			}
+
+			if got := bus.lines("stdout"); !reflect.DeepEqual(got, tt.stdout) {
+				t.Errorf("got stdout %q, want %q", got, tt.stdout)
+			}
+
+			if tt.stderr == nil {
+				tt.stderr = []string{}
+			}
+
+			if got := bus.lines("stderr"); !reflect.DeepEqual(got, tt.stderr) {
+				t.Errorf("got stderr %q, want %q", got, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestTaskEnv(t *testing.T) {
+	t.Setenv("J9_TEST_PROCESS", "process")
+	t.Setenv("J9_TEST_RUN", "process")
+	t.Setenv("J9_TEST_TASK", "process")
+	ctx := TaskContext{
+		Context: primitives.Context{Env: map[string]string{"J9_TEST_RUN": "run", "J9_TEST_TASK": "run"}},
+		State:   &TaskState{Env: map[string]string{"J9_TEST_TASK": "task"}},
+	}
+
+	got := map[string]string{}
+	for _, kv := range taskEnv(ctx) {
+		if k, v, _ := strings.Cut(kv, "="); strings.HasPrefix(k, "J9_TEST_") {
+			got[k] = v
+		}
+	}
+
+	want := map[string]string{"J9_TEST_PROCESS": "process", "J9_TEST_RUN": "run", "J9_TEST_TASK": "task"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
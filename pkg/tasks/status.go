@@ -0,0 +1,167 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TaskStatus is the lifecycle state of a task. The numeric values of the
+// finished states match the plain integers TaskResult.Status used to hold.
+type TaskStatus int
+
+const (
+	StatusPending   TaskStatus = 0
+	StatusSucceeded TaskStatus = 1
+	StatusSkipped   TaskStatus = 2
+	StatusQueued    TaskStatus = 3
+	StatusRunning   TaskStatus = 4
+	StatusCancelled TaskStatus = 5
+	StatusTimedOut  TaskStatus = 6
+	StatusFailed    TaskStatus = 10
+)
+
+var statusNames = map[TaskStatus]string{
+	StatusPending:   "pending",
+	StatusQueued:    "queued",
+	StatusRunning:   "running",
+	StatusSucceeded: "succeeded",
+	StatusSkipped:   "skipped",
+	StatusCancelled: "cancelled",
+	StatusTimedOut:  "timed_out",
+	StatusFailed:    "failed",
+}
+
+// transitions lists the states each state may move to. Finished states have
+// no way out. A task may fail before it starts, e.g. when its uses or with
+// cannot be resolved.
+var transitions = map[TaskStatus][]TaskStatus{
+	StatusPending: {StatusQueued, StatusRunning, StatusSkipped, StatusFailed, StatusCancelled},
+	StatusQueued:  {StatusRunning, StatusSkipped, StatusFailed, StatusCancelled},
+	StatusRunning: {StatusSucceeded, StatusSkipped, StatusFailed, StatusCancelled, StatusTimedOut},
+}
+
+func ParseTaskStatus(s string) (TaskStatus, error) {
+	for status, name := range statusNames {
+		if name == s {
+			return status, nil
+		}
+	}
+
+	return StatusPending, fmt.Errorf("unknown task status %q", s)
+}
+
+func (s TaskStatus) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+
+	return "unknown(" + strconv.Itoa(int(s)) + ")"
+}
+
+// IsFinished reports whether the status is terminal.
+func (s TaskStatus) IsFinished() bool {
+	switch s {
+	case StatusSucceeded, StatusSkipped, StatusCancelled, StatusTimedOut, StatusFailed:
+		return true
+	}
+
+	return false
+}
+
+func (s TaskStatus) CanTransition(to TaskStatus) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s TaskStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON accepts a status name or, for results written before the
+// status had names, its number.
+func (s *TaskStatus) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		if _, ok := statusNames[TaskStatus(n)]; !ok {
+			return fmt.Errorf("unknown task status %d", n)
+		}
+
+		*s = TaskStatus(n)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	status, err := ParseTaskStatus(name)
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+func (s TaskStatus) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+func (s *TaskStatus) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("task status must be a scalar on line %d at column %d", node.Line, node.Column)
+	}
+
+	if n, err := strconv.Atoi(node.Value); err == nil {
+		if _, ok := statusNames[TaskStatus(n)]; !ok {
+			return fmt.Errorf("unknown task status %d on line %d at column %d", n, node.Line, node.Column)
+		}
+
+		*s = TaskStatus(n)
+		return nil
+	}
+
+	status, err := ParseTaskStatus(node.Value)
+	if err != nil {
+		return fmt.Errorf("%s on line %d at column %d", err.Error(), node.Line, node.Column)
+	}
+
+	*s = status
+	return nil
+}
+
+// TransitionError is returned when a task is moved to a state it cannot reach
+// from its current one.
+type TransitionError struct {
+	Id   string
+	From TaskStatus
+	To   TaskStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("task %s cannot move from %s to %s", e.Id, e.From, e.To)
+}
+
+// StatusEvent is emitted for every status transition of a TaskResult.
+type StatusEvent struct {
+	Id     string
+	From   TaskStatus
+	To     TaskStatus
+	Reason string
+	At     time.Time
+	Result *TaskResult
+}
+
+func (e *StatusEvent) Kind() string {
+	return "task.status"
+}
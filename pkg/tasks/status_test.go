@@ -0,0 +1,149 @@
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTaskStatusCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to TaskStatus
+		want     bool
+	}{
+		{StatusPending, StatusQueued, true},
+		{StatusPending, StatusRunning, true},
+		{StatusPending, StatusFailed, true},
+		{StatusPending, StatusSucceeded, false},
+		{StatusPending, StatusTimedOut, false},
+		{StatusQueued, StatusRunning, true},
+		{StatusQueued, StatusFailed, true},
+		{StatusQueued, StatusCancelled, true},
+		{StatusQueued, StatusPending, false},
+		{StatusRunning, StatusSucceeded, true},
+		{StatusRunning, StatusTimedOut, true},
+		{StatusRunning, StatusQueued, false},
+		{StatusSucceeded, StatusFailed, false},
+		{StatusFailed, StatusRunning, false},
+		{StatusCancelled, StatusSucceeded, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: got %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTaskResultFail(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name     string
+		prepare  func(r *TaskResult)
+		status   TaskStatus
+		rejected bool
+	}{
+		{"before start", func(r *TaskResult) {}, StatusFailed, false},
+		{"queued", func(r *TaskResult) { r.Queue() }, StatusFailed, false},
+		{"running", func(r *TaskResult) { r.Start() }, StatusFailed, false},
+		{"finished", func(r *TaskResult) { r.Start().Finish() }, StatusSucceeded, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &TaskResult{Id: "build"}
+			tt.prepare(r)
+			r.Fail(boom)
+
+			if r.Status != tt.status {
+				t.Errorf("got status %s, want %s", r.Status, tt.status)
+			}
+
+			if !errors.Is(r.Error, boom) {
+				t.Errorf("got error %v, want it to include %v", r.Error, boom)
+			}
+
+			var terr *TransitionError
+			if got := errors.As(r.Error, &terr); got != tt.rejected {
+				t.Errorf("got rejected transition %v, want %v", got, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestTaskResultNilError(t *testing.T) {
+	tests := []struct {
+		name   string
+		end    func(r *TaskResult) *TaskResult
+		status TaskStatus
+	}{
+		{"fail", func(r *TaskResult) *TaskResult { return r.Fail(nil) }, StatusFailed},
+		{"set error", func(r *TaskResult) *TaskResult { return r.SetError(nil) }, StatusFailed},
+		{"time out", func(r *TaskResult) *TaskResult { return r.TimeOut(nil) }, StatusTimedOut},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.end((&TaskResult{Id: "build"}).Start())
+			if r.Status != tt.status || r.Error != nil || r.Reason != "" {
+				t.Errorf("got status %s, error %v and reason %q, want %s without either", r.Status, r.Error, r.Reason, tt.status)
+			}
+		})
+	}
+}
+
+func TestTaskStatusEncoding(t *testing.T) {
+	for status, name := range statusNames {
+		data, err := json.Marshal(status)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != `"`+name+`"` {
+			t.Errorf("json: got %s, want %q", data, name)
+		}
+
+		var fromJSON TaskStatus
+		if err := json.Unmarshal(data, &fromJSON); err != nil || fromJSON != status {
+			t.Errorf("json: %s decoded to %s, %v", data, fromJSON, err)
+		}
+
+		data, err = yaml.Marshal(status)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fromYAML TaskStatus
+		if err := yaml.Unmarshal(data, &fromYAML); err != nil || fromYAML != status {
+			t.Errorf("yaml: %s decoded to %s, %v", data, fromYAML, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		json  string
+		yaml  string
+		want  TaskStatus
+		error bool
+	}{
+		{"number", `10`, `10`, StatusFailed, false},
+		{"name", `"timed_out"`, `timed_out`, StatusTimedOut, false},
+		{"unknown number", `7`, `7`, StatusPending, true},
+		{"unknown name", `"done"`, `done`, StatusPending, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromJSON, fromYAML TaskStatus
+			if err := json.Unmarshal([]byte(tt.json), &fromJSON); (err != nil) != tt.error || fromJSON != tt.want {
+				t.Errorf("json: got %s, %v", fromJSON, err)
+			}
+
+			if err := yaml.Unmarshal([]byte(tt.yaml), &fromYAML); (err != nil) != tt.error || fromYAML != tt.want {
+				t.Errorf("yaml: got %s, %v", fromYAML, err)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package tasks
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"github.com/jolt9dev/go-jolt9/pkg/expr/j9expr"
 	"github.com/jolt9dev/go-jolt9/pkg/primitives"
 	"github.com/jolt9dev/go-xstrings"
 	"gopkg.in/yaml.v3"
@@ -16,14 +21,65 @@ type Task struct {
 	Name        string
 	Uses        string
 	Description string
-	With        map[string]expr.Expression
-	Env         map[string]expr.Expression
-	Timeout     *expr.Expression
-	Force       *expr.Expression
-	If          *expr.Expression
-	Cwd         *expr.Expression
+	With        map[string]*expr.AnyExpression
+	Env         map[string]*expr.StringExpression
+	Timeout     *expr.StringExpression
+	Force       *expr.BoolExpression
+	If          *expr.BoolExpression
+	Cwd         *expr.StringExpression
 	Needs       []string
-	RunExpr     *expr.Expression
+	RunExpr     *expr.StringExpression
+	Shell       string
+
+	Retries      *expr.Uint32Expression
+	RetryDelay   *expr.StringExpression
+	RetryBackoff RetryBackoff
+	RetryOn      *RetryOn
+
+	// ContinueOnError lets the run go on as if the task had succeeded when it
+	// fails or times out.
+	ContinueOnError *expr.BoolExpression
+
+	// Matrix is set on a task definition that TaskMap.ExpandMatrices replaces
+	// by instances. Each instance records the task it came from and its own
+	// values, exposed to expressions as the `matrix` context.
+	Matrix       *Matrix
+	MatrixOf     string
+	MatrixValues map[string]interface{}
+
+	// dialect is the expression language the task was loaded with. Workflow
+	// sets it before decoding; tasks decoded on their own use the default.
+	dialect     expr.Dialect
+	source      yamlSource
+	needsAt     map[string]Location
+	maxParallel int
+}
+
+// Location is a 1-based position in a workflow file.
+type Location struct {
+	Line   int
+	Column int
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("line %d at column %d", l.Line, l.Column)
+}
+
+// NeedLocation returns where dep was listed in the task's `needs`. Needs
+// added in code or inferred from expressions have no location.
+func (t *Task) NeedLocation(dep string) (Location, bool) {
+	loc, ok := t.needsAt[dep]
+	return loc, ok
+}
+
+// Dialect returns the expression dialect used to compile the task's
+// expressions.
+func (t *Task) Dialect() expr.Dialect {
+	if t.dialect == nil {
+		t.dialect = j9expr.New()
+	}
+
+	return t.dialect
 }
 
 func (a Task) Compare(b Task) int {
@@ -38,7 +94,19 @@ func (a Task) Compare(b Task) int {
 	return 1
 }
 
+// Eval evaluates the task's expressions into ctx.State. The task's env and
+// its INPUT_* variables go to State.Env; ctx.Env is only read, so that what
+// later tasks see of the environment is up to the executor. When `if` is
+// false, Eval stops there and leaves the rest of the task unevaluated.
 func (t *Task) Eval(ctx *TaskContext) error {
+	if ctx.Evaluator == nil {
+		ctx.Evaluator = t.Dialect()
+	}
+
+	evaluator := ctx.Evaluator
+	if c, ok := evaluator.(expr.Cancellable); ok && ctx.Signal != nil {
+		evaluator = c.WithSignal(ctx.Signal)
+	}
 
 	if ctx.State == nil {
 		ctx.State = &TaskState{
@@ -61,23 +129,72 @@ func (t *Task) Eval(ctx *TaskContext) error {
 		ctx.State.Env[k] = v
 	}
 
-	data := make(map[string]interface{})
-	data["env"] = ctx.Env
-	data["secrets"] = ctx.Secrets
-	data["outputs"] = mapOutputs(ctx.Outputs)
-	data["vars"] = mapOutputs(ctx.Vars)
+	data := t.expressionData(ctx, "")
+
+	// continue-on-error comes first so that it also covers failures to
+	// evaluate the rest of the task.
+	if t.ContinueOnError != nil {
+		if !t.ContinueOnError.IsEvaluated() {
+			err := t.ContinueOnError.Eval(evaluator, data)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx.State.ContinueOnError = t.ContinueOnError.Bool()
+	}
+
+	if t.Cwd != nil {
+		if !t.Cwd.IsEvaluated() {
+			err := t.Cwd.Eval(evaluator, data)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx.State.Cwd = t.Cwd.String()
+	}
+
+	data[j9expr.ContextKey].(map[string]interface{})["cwd"] = ctx.State.Cwd
+
+	// cwd comes before the condition so that the filesystem functions in
+	// `if` resolve against the task's directory. A task whose condition is
+	// false is skipped, so the rest of it is not evaluated and cannot fail it.
+	if t.If != nil {
+		if !t.If.IsEvaluated() {
+			err := t.If.Eval(evaluator, data)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx.State.If = t.If.Bool()
+		if !ctx.State.If {
+			return nil
+		}
+	}
 
+	// env is evaluated in key order against the environment the task
+	// inherits, so one entry never sees another.
 	if len(t.Env) > 0 {
-		for key, value := range t.Env {
-			if !value.IsEvaluated {
-				err := value.Eval(ctx.Evaluator, data)
+		for _, key := range sortedKeys(t.Env) {
+			value := t.Env[key]
+			if !value.IsEvaluated() {
+				err := value.Eval(evaluator, withSecrets(data, value, ctx.Secrets))
 				if err != nil {
 					return err
 				}
+
+				if expr.Tainted(value, value.Value(), ctx.Secrets) {
+					value.Taint()
+				}
+			}
+
+			if value.IsTainted() {
+				maskValue(ctx.State, value.String(), ctx.Secrets)
 			}
 
-			ctx.Env[key] = value.Value.(string)
-			ctx.State.Env[key] = value.Value.(string)
+			ctx.State.Env[key] = value.String()
 		}
 	}
 
@@ -86,212 +203,394 @@ func (t *Task) Eval(ctx *TaskContext) error {
 			return fmt.Errorf("inputs are not defined for task %s", t.Id)
 		}
 
-		for key, value := range t.With {
+		for _, key := range sortedKeys(t.With) {
+			value := t.With[key]
 			in, ok := ctx.Descriptor.Inputs[key]
 			if !ok {
 				return fmt.Errorf("input %s is not defined for task %s", key, t.Id)
 			}
 
-			if !value.IsEvaluated {
-				err := value.Eval(ctx.Evaluator, data)
+			if !value.IsEvaluated() {
+				err := value.Eval(evaluator, withSecrets(data, value, ctx.Secrets))
 				if err != nil {
 					return err
 				}
-			}
 
-			value := value.Value.(string)
+				if !in.IsSecret && expr.Tainted(value, value.Value(), ctx.Secrets) {
+					value.Taint()
+				}
+			}
 
-			if in.IsRequired && value == "" {
+			str := expr.ToString(value.Value())
+			if in.IsSecret || value.IsTainted() {
+				maskValue(ctx.State, str, ctx.Secrets)
+			}
+			if in.IsRequired && str == "" {
 				return fmt.Errorf("input %s is required for task %s", key, t.Id)
 			}
 
 			envName := xstrings.Underscore(key, xstrings.Screaming)
 			envName = "INPUT_" + envName
-			ctx.Env[envName] = value
-
-			switch in.Type {
-			case "int":
-				fallthrough
-			case "int64":
-				i := int64(0)
-				if value != "" {
-					i2, err := strconv.ParseInt(value, 10, 64)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid integer for task %s", key, t.Id)
-					}
-
-					i = i2
-				} else if in.Default != nil {
-					if v, ok := in.Default.(int64); ok {
-						i = v
-					}
-				}
-				ctx.State.Inputs.Set(key, i)
-			case "int32":
-				i := int32(0)
-				if value != "" {
-					i2, err := strconv.ParseInt(value, 10, 32)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid integer for task %s", key, t.Id)
-					}
-
-					i = int32(i2)
-				} else if in.Default != nil {
-					if v, ok := in.Default.(int32); ok {
-						i = v
-					}
-				}
-				ctx.State.Inputs.Set(key, i)
-			case "uint":
-				fallthrough
-			case "uint64":
-				i := uint64(0)
-				if value != "" {
-					i2, err := strconv.ParseUint(value, 10, 64)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid unsigned integer for task %s", key, t.Id)
-					}
-
-					i = i2
-				} else if in.Default != nil {
-					if v, ok := in.Default.(uint64); ok {
-						i = v
-					}
-				}
-				ctx.State.Inputs.Set(key, i)
-			case "uint32":
-				i := uint32(0)
-				if value != "" {
-					i2, err := strconv.ParseUint(value, 10, 32)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid unsigned integer for task %s", key, t.Id)
-					}
-
-					i = uint32(i2)
-				} else if in.Default != nil {
-					if v, ok := in.Default.(uint32); ok {
-						i = v
-					}
-				}
-				ctx.State.Inputs.Set(key, i)
-			case "number":
-				fallthrough
-			case "float":
-				fallthrough
-			case "float64":
-				f := float64(0)
-				if value != "" {
-					f2, err := strconv.ParseFloat(value, 64)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid float for task %s", key, t.Id)
-					}
-
-					f = f2
-				} else if in.Default != nil {
-					if v, ok := in.Default.(float64); ok {
-						f = v
-					}
-				}
-				ctx.State.Inputs.Set(key, f)
-
-			case "float32":
-				f := float32(0)
-				if value != "" {
-					f2, err := strconv.ParseFloat(value, 32)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid float for task %s", key, t.Id)
-					}
-
-					f = float32(f2)
-				} else if in.Default != nil {
-					if v, ok := in.Default.(float32); ok {
-						f = v
-					}
-				}
-				ctx.State.Inputs.Set(key, f)
-			case "bool":
-				b := false
-				if value != "" {
-					b2, err := strconv.ParseBool(value)
-					if err != nil {
-						return fmt.Errorf("input %s must be a valid boolean for task %s", key, t.Id)
-					}
-
-					b = b2
-				} else if in.Default != nil {
-					if v, ok := in.Default.(bool); ok {
-						b = v
-					}
-				}
-				ctx.State.Inputs.Set(key, b)
+			ctx.State.Env[envName] = str
+
+			input, err := inputValue(in, value.Value())
+			if err != nil {
+				return fmt.Errorf("input %s must be a valid %s for task %s: %w", key, inputTypeName(in.Type), t.Id, err)
 			}
 
-			for _, input := range ctx.Descriptor.Inputs {
-				if input.IsRequired && !ctx.State.Inputs.Has(input.Name) {
-					return fmt.Errorf("input %s is required for task %s", input.Name, t.Id)
-				}
+			ctx.State.Inputs.Set(key, input)
+		}
+
+		for key, input := range ctx.Descriptor.Inputs {
+			if input.IsRequired && !ctx.State.Inputs.Has(key) {
+				return fmt.Errorf("input %s is required for task %s", key, t.Id)
 			}
 		}
 	}
 
 	if t.Timeout != nil {
-		if !t.Timeout.IsEvaluated {
-			err := t.Timeout.Eval(ctx.Evaluator, data)
+		if !t.Timeout.IsEvaluated() {
+			err := t.Timeout.Eval(evaluator, data)
 			if err != nil {
 				return err
 			}
 		}
 
-		ctx.State.Timeout = t.Timeout.Value.(uint32)
+		timeout, err := ParseDuration(t.Timeout.String())
+		if err != nil {
+			return fmt.Errorf("timeout for task %s: %w", t.Id, err)
+		}
+
+		ctx.State.Timeout = timeout
 	}
 
-	if t.Force != nil {
-		if !t.Force.IsEvaluated {
-			err := t.Force.Eval(ctx.Evaluator, data)
+	if t.Retries != nil {
+		if !t.Retries.IsEvaluated() {
+			err := t.Retries.Eval(evaluator, data)
 			if err != nil {
 				return err
 			}
 		}
 
-		ctx.State.Force = t.Force.Value.(bool)
+		ctx.State.Retries = t.Retries.Uint32()
 	}
 
-	if t.Cwd != nil {
-		if !t.Cwd.IsEvaluated {
-			err := t.Cwd.Eval(ctx.Evaluator, data)
+	if t.RetryDelay != nil {
+		if !t.RetryDelay.IsEvaluated() {
+			err := t.RetryDelay.Eval(evaluator, data)
 			if err != nil {
 				return err
 			}
 		}
 
-		ctx.State.Cwd = t.Cwd.Value.(string)
+		delay, err := ParseDuration(t.RetryDelay.String())
+		if err != nil {
+			return fmt.Errorf("retry-delay for task %s: %w", t.Id, err)
+		}
+
+		ctx.State.RetryDelay = delay
 	}
 
-	if t.If != nil {
-		if !t.If.IsEvaluated {
-			err := t.If.Eval(ctx.Evaluator, data)
+	ctx.State.RetryBackoff = t.RetryBackoff
+	if ctx.State.RetryBackoff == "" {
+		ctx.State.RetryBackoff = BackoffConstant
+	}
+
+	if t.Force != nil {
+		if !t.Force.IsEvaluated() {
+			err := t.Force.Eval(evaluator, data)
 			if err != nil {
 				return err
 			}
 		}
 
-		ctx.State.If = t.If.Value.(bool)
+		ctx.State.Force = t.Force.Bool()
 	}
 
 	if t.RunExpr != nil {
-		if !t.RunExpr.IsEvaluated {
-			err := t.RunExpr.Eval(ctx.Evaluator, data)
+		if !t.RunExpr.IsEvaluated() {
+			err := t.RunExpr.Eval(evaluator, withSecrets(data, t.RunExpr, ctx.Secrets))
 			if err != nil {
 				return err
 			}
+
+			if expr.Tainted(t.RunExpr, t.RunExpr.Value(), ctx.Secrets) {
+				t.RunExpr.Taint()
+			}
+		}
+
+		if t.RunExpr.IsTainted() {
+			maskValue(ctx.State, t.RunExpr.String(), ctx.Secrets)
 		}
 
-		ctx.State.RunExpr = t.RunExpr.Value.(string)
+		ctx.State.RunExpr = t.RunExpr.String()
 	}
 
 	return nil
 }
 
+func (t *Task) expressionData(ctx *TaskContext, cwd string) map[string]interface{} {
+	matrix := t.MatrixValues
+	if matrix == nil {
+		matrix = map[string]interface{}{}
+	}
+
+	data := make(map[string]interface{})
+	data["matrix"] = matrix
+	// env is a copy, so that evaluating the task's env into State.Env does
+	// not change what its expressions read.
+	data["env"] = maps.Clone(ctx.Env)
+	if ctx.State != nil {
+		data["env"] = maps.Clone(ctx.State.Env)
+	}
+	data["outputs"] = mapOutputs(ctx.Outputs)
+	data["vars"] = mapOutputs(ctx.Vars)
+	data["needs"] = mapNeeds(ctx.Needs)
+	data[j9expr.ContextKey] = map[string]interface{}{
+		"workspace": ctx.Workspace,
+		"cwd":       cwd,
+		"status":    ctx.Upstream,
+	}
+
+	return data
+}
+
+// mapNeeds exposes each dependency's `result`, `outputs` and `duration` in
+// seconds.
+func mapNeeds(needs map[string]*TaskResult) map[string]interface{} {
+	result := make(map[string]interface{}, len(needs))
+	for id, r := range needs {
+		outputs := map[string]interface{}{}
+		if r.Outputs != nil {
+			outputs = mapOutputs(r.Outputs)
+		}
+
+		duration := 0.0
+		if !r.StartedAt.IsZero() && !r.FinishedAt.IsZero() {
+			duration = r.FinishedAt.Sub(r.StartedAt).Seconds()
+		}
+
+		result[id] = map[string]interface{}{
+			"result":   r.Status.String(),
+			"outputs":  outputs,
+			"duration": duration,
+		}
+	}
+
+	return result
+}
+
+// hasStatusCondition reports whether the task's `if` calls one of the status
+// functions, in which case it decides for itself whether to run after a
+// dependency failed or was cancelled. The calls are read from the compiled
+// expression, so names inside strings do not count.
+func (t *Task) hasStatusCondition() bool {
+	if t.If == nil {
+		return false
+	}
+
+	c, ok := t.If.Compiled().(expr.Caller)
+	if !ok {
+		return false
+	}
+
+	for _, name := range c.Calls() {
+		if j9expr.IsStatusFunction(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maskValue registers a tainted value, and every secret it contains, with the
+// task state so that neither is displayed.
+func maskValue(state *TaskState, value string, secrets map[string]string) {
+	state.AddMask(value)
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(value, secret) {
+			state.AddMask(secret)
+		}
+	}
+}
+
+// withSecrets returns data with the secrets context added for an expression
+// whose value is passed to the task. Only the secrets the expression names
+// are exposed; an expression that uses the secrets context as a whole, or
+// whose dialect cannot report references, sees all of them. Conditions and
+// other settings that only steer the runner never see secrets; they fail to
+// load when they read them, see compileSetting.
+func withSecrets(data map[string]interface{}, expression primitives.Expression, secrets map[string]string) map[string]interface{} {
+	scoped := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		scoped[k] = v
+	}
+
+	scoped["secrets"] = secrets
+	r, ok := expression.Compiled().(expr.Referencer)
+	if !ok {
+		return scoped
+	}
+
+	names := map[string]string{}
+	for _, ref := range r.References() {
+		if len(ref) == 0 || !strings.EqualFold(ref[0], "secrets") {
+			continue
+		}
+
+		if len(ref) == 1 {
+			return scoped
+		}
+
+		for name, value := range secrets {
+			if strings.EqualFold(name, ref[1]) {
+				names[name] = value
+			}
+		}
+	}
+
+	scoped["secrets"] = names
+	return scoped
+}
+
+// ParseDuration reads a timeout or retry delay given either as a whole number
+// of seconds or as a Go duration string such as `90s` or `1h30m`. An empty
+// value is zero, which for a timeout means none.
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("duration %q must not be negative", value)
+	}
+
+	return d, nil
+}
+
+// inputValue converts an evaluated `with` value to the input's declared type,
+// falling back to the input's default when the value is empty.
+func inputValue(in primitives.InputDescriptor, value interface{}) (interface{}, error) {
+	if s, ok := value.(string); (ok && s == "") || value == nil {
+		if in.Default != nil {
+			value = in.Default
+		}
+	}
+
+	return coerceDeclared(value, in.Type)
+}
+
+// coerceDeclared converts value to a type named in a descriptor.
+func coerceDeclared(value interface{}, typ string) (interface{}, error) {
+	switch typ {
+	case "", "string":
+		typ = "string"
+	case "int":
+		typ = "int64"
+	case "uint":
+		typ = "uint64"
+	case "number", "float":
+		typ = "float64"
+	}
+
+	return expr.Coerce(value, typ)
+}
+
+func inputTypeName(typ string) string {
+	switch typ {
+	case "int", "int32", "int64":
+		return "integer"
+	case "uint", "uint32", "uint64":
+		return "unsigned integer"
+	case "number", "float", "float32", "float64":
+		return "float"
+	case "bool":
+		return "boolean"
+	case "array":
+		return "array"
+	case "object":
+		return "object"
+	}
+
+	return "string"
+}
+
+type taskExpression struct {
+	key  string
+	expr primitives.Expression
+}
+
+// expressions lists every expression on the task in a stable order, keyed by
+// the YAML path it was read from.
+func (t *Task) expressions() []taskExpression {
+	result := []taskExpression{}
+	for _, key := range sortedKeys(t.With) {
+		result = append(result, taskExpression{key: "with." + key, expr: t.With[key]})
+	}
+
+	for _, key := range sortedKeys(t.Env) {
+		result = append(result, taskExpression{key: "env." + key, expr: t.Env[key]})
+	}
+
+	if t.Timeout != nil {
+		result = append(result, taskExpression{key: "timeout", expr: t.Timeout})
+	}
+
+	if t.Retries != nil {
+		result = append(result, taskExpression{key: "retries", expr: t.Retries})
+	}
+
+	if t.RetryDelay != nil {
+		result = append(result, taskExpression{key: "retry-delay", expr: t.RetryDelay})
+	}
+
+	if t.RetryOn != nil && t.RetryOn.Condition != nil {
+		result = append(result, taskExpression{key: "retry-on", expr: t.RetryOn.Condition})
+	}
+
+	if t.Force != nil {
+		result = append(result, taskExpression{key: "force", expr: t.Force})
+	}
+
+	if t.If != nil {
+		result = append(result, taskExpression{key: "if", expr: t.If})
+	}
+
+	if t.ContinueOnError != nil {
+		result = append(result, taskExpression{key: "continue-on-error", expr: t.ContinueOnError})
+	}
+
+	if t.Cwd != nil {
+		result = append(result, taskExpression{key: "cwd", expr: t.Cwd})
+	}
+
+	if t.RunExpr != nil {
+		result = append(result, taskExpression{key: "run", expr: t.RunExpr})
+	}
+
+	return result
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
 func mapOutputs(outputs *primitives.ObjectMap) map[string]interface{} {
 	result := make(map[string]interface{})
 	for _, key := range outputs.Keys() {
@@ -316,56 +615,27 @@ func mapOutputs(outputs *primitives.ObjectMap) map[string]interface{} {
 }
 
 func (t *Task) SetWith(inputs map[string]string) *Task {
-	t.With = make(map[string]expr.Expression)
+	t.With = make(map[string]*expr.AnyExpression)
 	for key, value := range inputs {
-		t.With[key] = expr.Expression{
-			Raw:         value,
-			Value:       value,
-			ValueString: value,
-			IsEvaluated: true,
-			Type:        "string",
-		}
+		t.With[key] = expr.NewAnyExpression(value)
 	}
 
 	return t
 }
 
-func (t *Task) SetWithEntry(key, value string) *Task {
+func (t *Task) SetWithEntry(key string, value interface{}) *Task {
 	if t.With == nil {
-		t.With = make(map[string]expr.Expression)
-	}
-
-	e, ok := t.With[key]
-	if !ok {
-		e = expr.Expression{
-			Raw:         value,
-			Value:       value,
-			ValueString: value,
-			IsEvaluated: true,
-			Type:        "string",
-		}
-		t.With[key] = e
-	} else {
-		e.Raw = value
-		e.Value = value
-		e.ValueString = value
-		e.IsEvaluated = true
-		t.With[key] = e
+		t.With = make(map[string]*expr.AnyExpression)
 	}
 
+	t.With[key] = expr.NewAnyExpression(value)
 	return t
 }
 
 func (t *Task) SetEnv(env map[string]string) *Task {
-	t.Env = make(map[string]expr.Expression)
+	t.Env = make(map[string]*expr.StringExpression)
 	for key, value := range env {
-		t.Env[key] = expr.Expression{
-			Raw:         value,
-			Value:       value,
-			ValueString: value,
-			IsEvaluated: true,
-			Type:        "string",
-		}
+		t.Env[key] = expr.NewStringExpression(value)
 	}
 
 	return t
@@ -373,78 +643,57 @@ func (t *Task) SetEnv(env map[string]string) *Task {
 
 func (t *Task) SetEnvEntry(key, value string) *Task {
 	if t.Env == nil {
-		t.Env = make(map[string]expr.Expression)
+		t.Env = make(map[string]*expr.StringExpression)
 	}
 
-	e, ok := t.Env[key]
-	if !ok {
-		e = expr.Expression{
-			Raw:         value,
-			Value:       value,
-			ValueString: value,
-			IsEvaluated: true,
-			Type:        "string",
-		}
-		t.Env[key] = e
-	} else {
-		e.Raw = value
-		e.Value = value
-		e.ValueString = value
-		e.IsEvaluated = true
-		t.Env[key] = e
-	}
+	t.Env[key] = expr.NewStringExpression(value)
+	return t
+}
 
+func (t *Task) SetTimeout(timeout time.Duration) *Task {
+	t.Timeout = expr.NewStringExpression(timeout.String())
 	return t
 }
 
-func (t *Task) SetTimeout(timeout uint32) *Task {
-	str := strconv.FormatUint(uint64(timeout), 10)
-	t.Timeout = &expr.Expression{
-		Raw:         str,
-		Value:       timeout,
-		ValueString: str,
-		IsEvaluated: true,
-		Type:        "uint32",
-	}
+func (t *Task) SetRetries(retries uint32) *Task {
+	t.Retries = expr.NewUint32Expression(retries)
+	return t
+}
 
+func (t *Task) SetRetryDelay(delay time.Duration, backoff RetryBackoff) *Task {
+	t.RetryDelay = expr.NewStringExpression(delay.String())
+	t.RetryBackoff = backoff
 	return t
 }
 
-func (t *Task) SetForce(force bool) *Task {
-	str := strconv.FormatBool(force)
-	t.Force = &expr.Expression{
-		Raw:         str,
-		Value:       force,
-		ValueString: str,
-		IsEvaluated: true,
-		Type:        "bool",
-	}
+// SetRetryOn limits retries to attempts that exit with one of codes.
+func (t *Task) SetRetryOn(codes ...int) *Task {
+	t.RetryOn = &RetryOn{ExitCodes: codes}
+	return t
+}
 
+func (t *Task) SetForce(force bool) *Task {
+	t.Force = expr.NewBoolExpression(force)
 	return t
 }
 
 func (t *Task) SetIf(condition bool) *Task {
-	str := strconv.FormatBool(condition)
-	t.If = &expr.Expression{
-		Raw:         str,
-		Value:       condition,
-		ValueString: str,
-		IsEvaluated: true,
-		Type:        "bool",
-	}
+	t.If = expr.NewBoolExpression(condition)
+	return t
+}
 
+func (t *Task) SetContinueOnError(continueOnError bool) *Task {
+	t.ContinueOnError = expr.NewBoolExpression(continueOnError)
 	return t
 }
 
-func (t *Task) SetCwd(cwd string) *Task {
-	t.Cwd = &expr.Expression{
-		Raw:         cwd,
-		Value:       cwd,
-		ValueString: cwd,
-		IsEvaluated: true,
-		Type:        "string",
-	}
+func (t *Task) SetRun(script string) *Task {
+	t.RunExpr = expr.NewStringExpression(script)
+	return t
+}
 
+func (t *Task) SetCwd(cwd string) *Task {
+	t.Cwd = expr.NewStringExpression(cwd)
 	return t
 }
 
@@ -453,6 +702,9 @@ func (s *Task) UnmarshalYAML(node *yaml.Node) error {
 		return fmt.Errorf("task must be a mapping on line %d at column %d", node.Line, node.Column)
 	}
 
+	dialect := s.Dialect()
+	source := s.source
+
 	for i := 0; i < len(node.Content); i += 2 {
 		keyNode := node.Content[i]
 		valueNode := node.Content[i+1]
@@ -472,8 +724,10 @@ func (s *Task) UnmarshalYAML(node *yaml.Node) error {
 				return fmt.Errorf("needs must be a sequence on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
+			s.needsAt = make(map[string]Location)
 			for _, n := range valueNode.Content {
 				s.Needs = append(s.Needs, n.Value)
+				s.needsAt[n.Value] = Location{Line: n.Line, Column: n.Column}
 			}
 
 		case "with":
@@ -481,21 +735,14 @@ func (s *Task) UnmarshalYAML(node *yaml.Node) error {
 				return fmt.Errorf("with must be a mapping on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
-			s.With = make(map[string]expr.Expression)
+			s.With = make(map[string]*expr.AnyExpression)
 			for i := 0; i < len(valueNode.Content); i += 2 {
 				kn := valueNode.Content[i]
 				vn := valueNode.Content[i+1]
 
-				target := expr.Expression{
-					Raw:         vn.Value,
-					IsEvaluated: false,
-					Type:        "string",
-				}
-
-				if vn.Value == "" || !strings.Contains(vn.Value, "${{") {
-					target.Value = vn.Value
-					target.ValueString = vn.Value
-					target.IsEvaluated = true
+				target := &expr.AnyExpression{}
+				if err := parseValue(dialect, source, &target.Typed, key+"."+kn.Value, vn); err != nil {
+					return err
 				}
 
 				s.With[kn.Value] = target
@@ -506,21 +753,14 @@ func (s *Task) UnmarshalYAML(node *yaml.Node) error {
 				return fmt.Errorf("env must be a mapping on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
-			s.Env = make(map[string]expr.Expression)
+			s.Env = make(map[string]*expr.StringExpression)
 			for i := 0; i < len(valueNode.Content); i += 2 {
 				kn := valueNode.Content[i]
 				vn := valueNode.Content[i+1]
 
-				target := expr.Expression{
-					Raw:         vn.Value,
-					IsEvaluated: false,
-					Type:        "string",
-				}
-
-				if vn.Value == "" || !strings.Contains(vn.Value, "${{") {
-					target.Value = vn.Value
-					target.ValueString = vn.Value
-					target.IsEvaluated = true
+				target := &expr.StringExpression{}
+				if err := parseValue(dialect, source, &target.Typed, key+"."+kn.Value, vn); err != nil {
+					return err
 				}
 
 				s.Env[kn.Value] = target
@@ -531,59 +771,105 @@ func (s *Task) UnmarshalYAML(node *yaml.Node) error {
 				return fmt.Errorf("timeout must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
-			s.Timeout = &expr.Expression{
-				Raw:         valueNode.Value,
-				IsEvaluated: false,
-				Type:        "uint32",
+			s.Timeout = &expr.StringExpression{}
+			actual := strings.TrimSpace(valueNode.Value)
+			if actual == "" {
+				s.Timeout.SetValue("0")
+				continue
+			}
+
+			if !dialect.IsExpression(actual) {
+				if _, err := ParseDuration(actual); err != nil {
+					return fmt.Errorf("timeout must be a number of seconds or a duration such as 90s or 1h30m on line %d at column %d", valueNode.Line, valueNode.Column)
+				}
+
+				s.Timeout.SetValue(actual)
+			} else if err := compileSetting(dialect, source, &s.Timeout.Typed, key, valueNode); err != nil {
+				return err
+			}
+
+		case "retries":
+			if valueNode.Kind != yaml.ScalarNode {
+				return fmt.Errorf("retries must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
+			s.Retries = &expr.Uint32Expression{}
 			actual := strings.TrimSpace(valueNode.Value)
 			if actual == "" {
-				s.Timeout.Value = 0
-				s.Timeout.IsEvaluated = true
-				s.Timeout.ValueString = "0"
+				s.Retries.SetValue(0)
 				continue
 			}
 
-			if !strings.Contains(actual, "${{") {
+			if !dialect.IsExpression(actual) {
 				v, err := strconv.ParseUint(actual, 10, 32)
 				if err != nil {
-					return fmt.Errorf("timeout must be a valid unsigned integer on line %d at column %d", valueNode.Line, valueNode.Column)
+					return fmt.Errorf("retries must be a non-negative integer on line %d at column %d", valueNode.Line, valueNode.Column)
 				}
 
-				s.Timeout.Value = uint32(v)
-				s.Timeout.IsEvaluated = true
-				s.Timeout.ValueString = actual
+				s.Retries.SetValue(uint32(v))
+			} else if err := compileSetting(dialect, source, &s.Retries.Typed, key, valueNode); err != nil {
+				return err
 			}
 
-		case "force":
+		case "retry-delay":
 			if valueNode.Kind != yaml.ScalarNode {
-				return fmt.Errorf("force must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
+				return fmt.Errorf("retry-delay must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			s.RetryDelay = &expr.StringExpression{}
+			actual := strings.TrimSpace(valueNode.Value)
+			if actual == "" {
+				s.RetryDelay.SetValue("0")
+				continue
+			}
+
+			if !dialect.IsExpression(actual) {
+				if _, err := ParseDuration(actual); err != nil {
+					return fmt.Errorf("retry-delay must be a number of seconds or a duration such as 500ms or 10s on line %d at column %d", valueNode.Line, valueNode.Column)
+				}
+
+				s.RetryDelay.SetValue(actual)
+			} else if err := compileSetting(dialect, source, &s.RetryDelay.Typed, key, valueNode); err != nil {
+				return err
+			}
+
+		case "retry-backoff":
+			backoff, err := ParseRetryBackoff(strings.TrimSpace(valueNode.Value))
+			if valueNode.Kind != yaml.ScalarNode || err != nil {
+				return fmt.Errorf("retry-backoff must be constant, linear or exponential on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			s.RetryBackoff = backoff
+
+		case "retry-on":
+			retryOn, err := parseRetryOn(dialect, source, key, valueNode)
+			if err != nil {
+				return err
 			}
 
-			s.Force = &expr.Expression{
-				Raw:         valueNode.Value,
-				IsEvaluated: false,
-				Type:        "bool",
+			s.RetryOn = retryOn
+
+		case "force":
+			if valueNode.Kind != yaml.ScalarNode {
+				return fmt.Errorf("force must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
+			s.Force = &expr.BoolExpression{}
 			actual := strings.TrimSpace(valueNode.Value)
 			if actual == "" {
-				s.Force.Value = false
-				s.Force.IsEvaluated = true
-				s.Force.ValueString = "false"
+				s.Force.SetValue(false)
 				continue
 			}
 
-			if !strings.Contains(actual, "${{") {
+			if !dialect.IsExpression(actual) {
 				v, err := strconv.ParseBool(actual)
 				if err != nil {
 					return fmt.Errorf("force must be a valid boolean on line %d at column %d", valueNode.Line, valueNode.Column)
 				}
 
-				s.Force.Value = v
-				s.Force.IsEvaluated = true
-				s.Force.ValueString = actual
+				s.Force.SetValue(v)
+			} else if err := compileSetting(dialect, source, &s.Force.Typed, key, valueNode); err != nil {
+				return err
 			}
 
 		case "if":
@@ -591,60 +877,350 @@ func (s *Task) UnmarshalYAML(node *yaml.Node) error {
 				return fmt.Errorf("if must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
-			s.If = &expr.Expression{
-				Raw:         valueNode.Value,
-				IsEvaluated: false,
-				Type:        "bool",
+			s.If = &expr.BoolExpression{}
+			actual := strings.TrimSpace(valueNode.Value)
+			if actual == "" {
+				s.If.SetValue(true)
+				continue
+			}
+
+			if !dialect.IsExpression(actual) {
+				v, err := strconv.ParseBool(actual)
+				if err != nil {
+					return fmt.Errorf("if must be a valid boolean on line %d at column %d", valueNode.Line, valueNode.Column)
+				}
+
+				s.If.SetValue(v)
+			} else if err := compileSetting(dialect, source, &s.If.Typed, key, valueNode); err != nil {
+				return err
+			}
+
+		case "continue-on-error":
+			if valueNode.Kind != yaml.ScalarNode {
+				return fmt.Errorf("continue-on-error must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
+			s.ContinueOnError = &expr.BoolExpression{}
 			actual := strings.TrimSpace(valueNode.Value)
 			if actual == "" {
-				s.If.Value = true
-				s.If.IsEvaluated = true
-				s.If.ValueString = "true"
+				s.ContinueOnError.SetValue(false)
 				continue
 			}
 
-			if !strings.Contains(actual, "${{") {
+			if !dialect.IsExpression(actual) {
 				v, err := strconv.ParseBool(actual)
 				if err != nil {
-					return fmt.Errorf("if must be a valid boolean on line %d at column %d", valueNode.Line, valueNode.Column)
+					return fmt.Errorf("continue-on-error must be a valid boolean on line %d at column %d", valueNode.Line, valueNode.Column)
 				}
 
-				s.If.Value = v
-				s.If.IsEvaluated = true
-				s.If.ValueString = actual
+				s.ContinueOnError.SetValue(v)
+			} else if err := compileSetting(dialect, source, &s.ContinueOnError.Typed, key, valueNode); err != nil {
+				return err
+			}
+
+		case "run":
+			if valueNode.Kind != yaml.ScalarNode {
+				return fmt.Errorf("run must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			s.RunExpr = &expr.StringExpression{}
+			actual := valueNode.Value
+			if actual == "" || !dialect.IsExpression(actual) {
+				s.RunExpr.SetValue(actual)
+			} else if err := compileExpression(dialect, source, &s.RunExpr.Typed, key, valueNode); err != nil {
+				return err
+			}
+
+		case "shell":
+			if _, err := ParseShell(valueNode.Value); valueNode.Kind != yaml.ScalarNode || err != nil {
+				return fmt.Errorf("shell must be bash, sh, pwsh, powershell, python or a command containing {0} on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			s.Shell = valueNode.Value
+
+		case "matrix":
+			matrix, err := parseMatrix(valueNode)
+			if err != nil {
+				return err
 			}
 
+			s.Matrix = matrix
+
 		case "cwd":
 			if valueNode.Kind != yaml.ScalarNode {
 				return fmt.Errorf("cwd must be a scalar on line %d at column %d", valueNode.Line, valueNode.Column)
 			}
 
-			s.Cwd = &expr.Expression{
-				Raw:         valueNode.Value,
-				IsEvaluated: false,
-				Type:        "string",
+			s.Cwd = &expr.StringExpression{}
+			actual := valueNode.Value
+			if actual == "" || !dialect.IsExpression(actual) {
+				s.Cwd.SetValue(actual)
+			} else if err := compileSetting(dialect, source, &s.Cwd.Typed, key, valueNode); err != nil {
+				return err
 			}
 
-			actual := valueNode.Value
-			if actual == "" {
-				s.Cwd.Value = ""
-				s.Cwd.IsEvaluated = true
-				s.Cwd.ValueString = ""
-				continue
+		default:
+			return fmt.Errorf("unknown key %s on line %d at column %d", key, keyNode.Line, keyNode.Column)
+		}
+	}
+
+	if s.RunExpr != nil && s.Uses != "" {
+		return fmt.Errorf("task cannot have both run and uses on line %d at column %d", node.Line, node.Column)
+	}
+
+	return nil
+}
+
+// parseRetryOn reads `retry-on`, which is either exit codes, given as one
+// integer or a sequence of them, or a boolean expression.
+func parseRetryOn(dialect expr.Dialect, source yamlSource, key string, node *yaml.Node) (*RetryOn, error) {
+	retryOn := &RetryOn{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			code, err := strconv.Atoi(strings.TrimSpace(n.Value))
+			if n.Kind != yaml.ScalarNode || err != nil {
+				return nil, fmt.Errorf("retry-on exit codes must be integers on line %d at column %d", n.Line, n.Column)
+			}
+
+			retryOn.ExitCodes = append(retryOn.ExitCodes, code)
+		}
+
+		return retryOn, nil
+
+	case yaml.ScalarNode:
+	default:
+		return nil, fmt.Errorf("retry-on must be exit codes or an expression on line %d at column %d", node.Line, node.Column)
+	}
+
+	actual := strings.TrimSpace(node.Value)
+	if dialect.IsExpression(actual) {
+		retryOn.Condition = &expr.BoolExpression{}
+		if err := compileSetting(dialect, source, &retryOn.Condition.Typed, key, node); err != nil {
+			return nil, err
+		}
+
+		return retryOn, nil
+	}
+
+	if code, err := strconv.Atoi(actual); err == nil {
+		retryOn.ExitCodes = []int{code}
+		return retryOn, nil
+	}
+
+	v, err := strconv.ParseBool(actual)
+	if err != nil {
+		return nil, fmt.Errorf("retry-on must be exit codes or an expression on line %d at column %d", node.Line, node.Column)
+	}
+
+	retryOn.Condition = expr.NewBoolExpression(v)
+	return retryOn, nil
+}
+
+// parseValue reads a `with` or `env` entry. Scalars are kept as strings;
+// mappings and sequences become a tree whose leaves may be expressions.
+func parseValue[T any](dialect expr.Dialect, source yamlSource, target *expr.Typed[T], key string, node *yaml.Node) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		root, err := parseTree(dialect, source, key, node)
+		if err != nil {
+			return err
+		}
+
+		target.SetTree(&expr.Tree{Root: root})
+		return nil
+	}
+
+	if node.Value == "" || !dialect.IsExpression(node.Value) {
+		value, err := expr.Coerce(node.Value, target.Type())
+		if err != nil {
+			return fmt.Errorf("%s on line %d at column %d: %w", key, node.Line, node.Column, err)
+		}
+
+		typed, _ := value.(T)
+		target.SetValue(typed)
+		return nil
+	}
+
+	return compileExpression(dialect, source, target, key, node)
+}
+
+func parseTree(dialect expr.Dialect, source yamlSource, key string, node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return parseTree(dialect, source, key, node.Alias)
+
+	case yaml.MappingNode:
+		result := make(map[string]interface{})
+		for i := 0; i < len(node.Content); i += 2 {
+			kn := node.Content[i]
+			value, err := parseTree(dialect, source, key+"."+kn.Value, node.Content[i+1])
+			if err != nil {
+				return nil, err
 			}
 
-			if !strings.Contains(actual, "${{") {
-				s.Cwd.Value = actual
-				s.Cwd.IsEvaluated = true
-				s.Cwd.ValueString = actual
+			result[kn.Value] = value
+		}
+		return result, nil
+
+	case yaml.SequenceNode:
+		result := make([]interface{}, len(node.Content))
+		for i, n := range node.Content {
+			value, err := parseTree(dialect, source, fmt.Sprintf("%s[%d]", key, i), n)
+			if err != nil {
+				return nil, err
 			}
 
+			result[i] = value
+		}
+		return result, nil
+	}
+
+	if dialect.IsExpression(node.Value) {
+		leaf := &expr.AnyExpression{}
+		if err := compileExpression(dialect, source, &leaf.Typed, key, node); err != nil {
+			return nil, err
+		}
+
+		return leaf, nil
+	}
+
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s on line %d at column %d: %w", key, node.Line, node.Column, err)
+	}
+
+	return value, nil
+}
+
+// compileExpression parses the expression held by node so that syntax errors
+// surface while loading, reported against the node's position in the file.
+func compileExpression[T any](dialect expr.Compiler, source yamlSource, target *expr.Typed[T], key string, node *yaml.Node) error {
+	compiled, err := dialect.Compile(node.Value)
+	if err != nil {
+		var se *expr.SyntaxError
+		if !errors.As(err, &se) {
+			return fmt.Errorf("invalid expression for %s on line %d at column %d: %w", key, node.Line, node.Column, err)
+		}
+
+		line, column := node.Line, node.Column
+		l, c := se.Position()
+		switch node.Style {
+		case yaml.LiteralStyle, yaml.FoldedStyle:
+			if bl, bc, ok := source.blockPosition(node, se.Offset); ok {
+				line, column = bl, bc
+				break
+			}
+
+			line += l
+			column = c
+		case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+			line += l - 1
+			if l == 1 {
+				column += c
+			} else {
+				column = c
+			}
 		default:
-			return fmt.Errorf("unknown key %s on line %d at column %d", key, keyNode.Line, keyNode.Column)
+			line += l - 1
+			if l == 1 {
+				column += c - 1
+			} else {
+				column = c
+			}
 		}
+
+		return fmt.Errorf("invalid expression for %s on line %d at column %d: %s", key, line, column, se.Message)
 	}
 
+	target.SetRaw(node.Value, compiled)
 	return nil
 }
+
+// compileSetting compiles an expression that steers the runner, such as `if`
+// or `timeout`, rather than one whose value is passed to the task. Those
+// never see secrets, so reading them is an error instead of a silent null.
+func compileSetting[T any](dialect expr.Compiler, source yamlSource, target *expr.Typed[T], key string, node *yaml.Node) error {
+	if err := compileExpression(dialect, source, target, key, node); err != nil {
+		return err
+	}
+
+	r, ok := target.Compiled().(expr.Referencer)
+	if !ok {
+		return nil
+	}
+
+	for _, ref := range r.References() {
+		if len(ref) > 0 && strings.EqualFold(ref[0], "secrets") {
+			return fmt.Errorf("%s cannot read secrets on line %d at column %d, only env, with and run can", key, node.Line, node.Column)
+		}
+	}
+
+	return nil
+}
+
+// yamlSource holds the lines of a workflow file. Block scalars lose their
+// indentation when decoded, so positions inside them are worked out from the
+// source.
+type yamlSource []string
+
+// blockPosition maps a byte offset into the value of a literal or folded
+// block scalar onto its line and column in the file. It walks the block's
+// content alongside the value, where folded line breaks have become spaces
+// or been dropped.
+func (s yamlSource) blockPosition(node *yaml.Node, offset int) (int, int, bool) {
+	// the content starts on the line after the block's header.
+	first := node.Line
+	indent := -1
+	lines := []string{}
+	for i := first; i < len(s); i++ {
+		line := strings.TrimSuffix(s[i], "\r")
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+
+		if indent < 0 {
+			indent = spaces
+		}
+
+		if spaces < indent {
+			break
+		}
+
+		lines = append(lines, line[indent:])
+	}
+
+	if indent < 0 {
+		return 0, 0, false
+	}
+
+	value := node.Value
+	row, col := 0, 0
+	for i := 0; i < offset && i < len(value); {
+		if row >= len(lines) {
+			return 0, 0, false
+		}
+
+		switch {
+		case col < len(lines[row]) && value[i] == lines[row][col]:
+			i++
+			col++
+		case col < len(lines[row]):
+			return 0, 0, false
+		case value[i] == '\n' || value[i] == ' ':
+			i++
+			row++
+			col = 0
+		default:
+			row++
+			col = 0
+		}
+	}
+
+	return first + 1 + row, indent + col + 1, true
+}
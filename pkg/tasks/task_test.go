@@ -0,0 +1,231 @@
+package tasks
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"github.com/jolt9dev/go-jolt9/pkg/expr/j9expr"
+	_ "github.com/jolt9dev/go-jolt9/pkg/expr/tmplexpr"
+	"github.com/jolt9dev/go-jolt9/pkg/primitives"
+)
+
+func TestTaskHasStatusCondition(t *testing.T) {
+	tests := []struct {
+		dialect string
+		cond    string
+		want    bool
+	}{
+		{"", "true", false},
+		{"", "${{ env.CI == 'true' }}", false},
+		{"", "${{ failure() }}", true},
+		{"", "${{ Always() }}", true},
+		{"", "${{ success() && env.CI }}", true},
+		{"", "${{ env.NAME == 'failure()' }}", false},
+		{"", "${{ contains(toJSON(needs), 'cancelled') }}", false},
+		{"template", "{{ if failure }}true{{ end }}", true},
+		{"template", "{{ eq .env.NAME \"always\" }}", false},
+	}
+
+	for _, tt := range tests {
+		w, err := ParseWorkflow([]byte("tasks:\n  a:\n    if: |-\n      "+tt.cond+"\n"), tt.dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := w.Tasks.Get("a").hasStatusCondition(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestTaskEval(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		want  map[string]string
+		run   bool
+		files []string
+	}{
+		{
+			name: "env reads the inherited environment",
+			yaml: `
+    env:
+      A: ${{ env.B }}-a
+      B: b
+      C: ${{ env.A }}-c
+`,
+			env:  map[string]string{"A": "parent", "B": "parent"},
+			want: map[string]string{"A": "parent-a", "B": "b", "C": "parent-c"},
+			run:  true,
+		},
+		{
+			name: "false condition stops evaluation",
+			yaml: `
+    if: ${{ env.RUN == 'yes' }}
+    timeout: ${{ env.NOT_A_DURATION }}
+    env:
+      A: a
+`,
+			env:  map[string]string{"RUN": "no", "NOT_A_DURATION": "soon"},
+			want: map[string]string{"RUN": "no", "NOT_A_DURATION": "soon"},
+			run:  false,
+		},
+		{
+			name: "condition reads files from the task's directory",
+			yaml: `
+    cwd: sub
+    if: ${{ fileExists('marker') }}
+`,
+			want:  map[string]string{},
+			run:   true,
+			files: []string{"sub/marker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := parseWorkflow(t, "tasks:\n  a:"+tt.yaml)
+			ctx := &TaskContext{Context: primitives.Context{
+				Env:     tt.env,
+				Outputs: &primitives.ObjectMap{},
+				Vars:    &primitives.ObjectMap{},
+			}}
+
+			if len(tt.files) > 0 {
+				ctx.Workspace = t.TempDir()
+				for _, name := range tt.files {
+					p := filepath.Join(ctx.Workspace, filepath.FromSlash(name))
+					if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(p, nil, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			inherited := maps.Clone(tt.env)
+			if err := w.Tasks.Get("a").Eval(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if ctx.State.If != tt.run {
+				t.Errorf("got if %v, want %v", ctx.State.If, tt.run)
+			}
+
+			if len(ctx.State.Env) != len(tt.want) {
+				t.Errorf("got env %v, want %v", ctx.State.Env, tt.want)
+			}
+
+			for k, v := range tt.want {
+				if ctx.State.Env[k] != v {
+					t.Errorf("got %s=%q, want %q", k, ctx.State.Env[k], v)
+				}
+			}
+
+			if !maps.Equal(ctx.Env, inherited) {
+				t.Errorf("the inherited environment changed to %v", ctx.Env)
+			}
+		})
+	}
+}
+
+func TestTaskEvalMasksSecrets(t *testing.T) {
+	secrets := map[string]string{"TOKEN": "s3cr3t", "OTHER": "hidden"}
+	tests := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		masks []string
+	}{
+		{
+			name:  "run reads a secret",
+			yaml:  "\n    run: echo ${{ secrets.TOKEN }}\n",
+			masks: []string{"echo s3cr3t", "s3cr3t"},
+		},
+		{
+			name:  "env reads a secret",
+			yaml:  "\n    env:\n      A: ${{ secrets.TOKEN }}\n",
+			masks: []string{"s3cr3t"},
+		},
+		{
+			name:  "env holds a secret it did not read",
+			yaml:  "\n    env:\n      A: ${{ env.LEAK }}-a\n",
+			env:   map[string]string{"LEAK": "s3cr3t"},
+			masks: []string{"s3cr3t-a", "s3cr3t"},
+		},
+		{
+			name: "clean values",
+			yaml: "\n    env:\n      A: ${{ env.B }}\n    run: echo ${{ env.B }}\n",
+			env:  map[string]string{"B": "public"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := parseWorkflow(t, "tasks:\n  a:"+tt.yaml)
+			ctx := &TaskContext{Context: primitives.Context{
+				Env:     tt.env,
+				Secrets: secrets,
+				Outputs: &primitives.ObjectMap{},
+				Vars:    &primitives.ObjectMap{},
+			}}
+
+			if err := w.Tasks.Get("a").Eval(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ctx.State.Masks) != len(tt.masks) {
+				t.Errorf("got masks %q, want %q", ctx.State.Masks, tt.masks)
+			}
+
+			for _, mask := range tt.masks {
+				if !slices.Contains(ctx.State.Masks, mask) {
+					t.Errorf("got masks %q, want them to include %q", ctx.State.Masks, mask)
+				}
+			}
+
+			for _, mask := range ctx.State.Masks {
+				if mask == "hidden" {
+					t.Errorf("masked %q, which no expression read", mask)
+				}
+			}
+		})
+	}
+}
+
+func TestWithSecrets(t *testing.T) {
+	secrets := map[string]string{"TOKEN": "s3cr3t", "OTHER": "hidden"}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"${{ secrets.TOKEN }}", []string{"TOKEN"}},
+		{"${{ secrets.token }}", []string{"TOKEN"}},
+		{"${{ secrets.TOKEN }}${{ secrets.OTHER }}", []string{"OTHER", "TOKEN"}},
+		{"${{ toJSON(secrets) }}", []string{"OTHER", "TOKEN"}},
+		{"${{ secrets[env.NAME] }}", []string{"OTHER", "TOKEN"}},
+		{"${{ env.A }}", []string{}},
+	}
+
+	dialect := j9expr.New()
+	for _, tt := range tests {
+		compiled, err := dialect.Compile(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e := &expr.StringExpression{}
+		e.SetRaw(tt.expr, compiled)
+		scoped := withSecrets(map[string]interface{}{}, e, secrets)["secrets"].(map[string]string)
+		got := slices.Sorted(maps.Keys(scoped))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got secrets %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
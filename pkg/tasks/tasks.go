@@ -1,6 +1,9 @@
 package tasks
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jolt9dev/go-jolt9/pkg/expr"
@@ -15,12 +18,44 @@ type TaskState struct {
 	Inputs      *primitives.ObjectMap
 	Outputs     *primitives.ObjectMap
 	Force       bool
-	Timeout     uint32
+	Timeout     time.Duration
 	If          bool
 	Env         map[string]string
 	Cwd         string
 	Needs       []string
 	RunExpr     string
+
+	Retries      uint32
+	RetryDelay   time.Duration
+	RetryBackoff RetryBackoff
+
+	ContinueOnError bool
+
+	// Masks holds values derived from secrets that must not be displayed.
+	Masks []string
+}
+
+// AddMask registers a value to be hidden by Mask.
+func (s *TaskState) AddMask(value string) {
+	if strings.TrimSpace(value) == "" || slices.Contains(s.Masks, value) {
+		return
+	}
+
+	// longer values go first so that a mask containing another is replaced
+	// whole.
+	s.Masks = append(s.Masks, value)
+	slices.SortStableFunc(s.Masks, func(a, b string) int {
+		return len(b) - len(a)
+	})
+}
+
+// Mask replaces every registered mask in text with `***`.
+func (s *TaskState) Mask(text string) string {
+	for _, mask := range s.Masks {
+		text = strings.ReplaceAll(text, mask, "***")
+	}
+
+	return text
 }
 
 type TaskContext struct {
@@ -28,59 +63,167 @@ type TaskContext struct {
 	State      *TaskState
 	Descriptor *TaskDescriptor
 	Evaluator  expr.Evaluator
+
+	// GracePeriod is how long a process started by the task may take to exit
+	// after it is asked to stop, before it is killed.
+	GracePeriod time.Duration
+
+	// Needs holds the results of the task's direct dependencies, exposed to
+	// expressions as the `needs` context.
+	Needs map[string]*TaskResult
+
+	// Upstream summarizes every task the task depends on, directly or not, for
+	// the success(), failure() and cancelled() functions.
+	Upstream string
 }
 
 type TaskResult struct {
 	Id         string
 	Outputs    *primitives.ObjectMap
-	Status     int
+	Status     TaskStatus
+	Reason     string
 	Error      error
 	StartedAt  time.Time
 	FinishedAt time.Time
+
+	// AllowFailure is set for tasks with continue-on-error; a failure or
+	// timeout then does not fail the run and dependents still run.
+	AllowFailure bool
+
+	// Attempts records every run of the task's delegate, including retries.
+	Attempts []TaskAttempt
+
+	// Env and Path hold what a successful task wrote to its J9_ENV and
+	// J9_PATH files for the tasks that run after it.
+	Env  map[string]string
+	Path []string
+
+	listeners []func(event *StatusEvent)
 }
 
-func (t *TaskResult) SetError(err error) *TaskResult {
-	t.Error = err
-	t.Status = 10
+// IsAllowedFailure reports whether the task failed or timed out but was
+// allowed to.
+func (t *TaskResult) IsAllowedFailure() bool {
+	return t.AllowFailure && (t.Status == StatusFailed || t.Status == StatusTimedOut)
+}
+
+// IsFailure reports whether the result fails the run: the task failed, timed
+// out or was cancelled, and its failure was not allowed.
+func (t *TaskResult) IsFailure() bool {
+	switch t.Status {
+	case StatusFailed, StatusTimedOut, StatusCancelled:
+		return !t.IsAllowedFailure()
+	}
+
+	return false
+}
+
+// Outcome describes the result's status, e.g. `failed (allowed)`.
+func (t *TaskResult) Outcome() string {
+	if t.IsAllowedFailure() {
+		return t.Status.String() + " (allowed)"
+	}
+
+	return t.Status.String()
+}
+
+// OnTransition registers fn to be called after every status transition.
+func (t *TaskResult) OnTransition(fn func(event *StatusEvent)) *TaskResult {
+	t.listeners = append(t.listeners, fn)
 	return t
 }
 
+// Transition moves the result to status and records why. StartedAt is set
+// when the task starts running and FinishedAt once it reaches a finished
+// status. A TransitionError is returned for a move the lifecycle does not
+// allow, leaving the result unchanged.
+func (t *TaskResult) Transition(status TaskStatus, reason string) error {
+	if !t.Status.CanTransition(status) {
+		return &TransitionError{Id: t.Id, From: t.Status, To: status}
+	}
+
+	now := time.Now()
+	from := t.Status
+	t.Status = status
+	t.Reason = reason
+	if status == StatusRunning {
+		t.StartedAt = now
+	}
+
+	if status.IsFinished() {
+		t.FinishedAt = now
+	}
+
+	event := &StatusEvent{Id: t.Id, From: from, To: status, Reason: reason, At: now, Result: t}
+	for _, fn := range t.listeners {
+		fn(event)
+	}
+
+	return nil
+}
+
+// The helpers below apply Transition for chaining and ignore transitions the
+// lifecycle does not allow; call Transition directly to detect those. Fail,
+// SetError and TimeOut always record err and join the TransitionError to it
+// when the move was rejected, so the failure is never lost.
+
+func (t *TaskResult) SetError(err error) *TaskResult {
+	return t.Fail(err)
+}
+
 func (t *TaskResult) SetOutputs(outputs *primitives.ObjectMap) *TaskResult {
 	t.Outputs = outputs
 	return t
 }
 
-func (t *TaskResult) SetStatus(status int) *TaskResult {
-	t.Status = status
+func (t *TaskResult) SetStatus(status TaskStatus) *TaskResult {
+	t.Transition(status, "")
+	return t
+}
+
+func (t *TaskResult) Queue() *TaskResult {
+	t.Transition(StatusQueued, "")
 	return t
 }
 
 func (t *TaskResult) Start() *TaskResult {
-	t.StartedAt = time.Now()
+	t.Transition(StatusRunning, "")
 	return t
 }
 
 func (t *TaskResult) Finish() *TaskResult {
-	t.FinishedAt = time.Now()
-	t.Status = 1
+	t.Transition(StatusSucceeded, "")
 	return t
 }
 
-func (t *TaskResult) Cancel() *TaskResult {
-	t.FinishedAt = time.Now()
-	t.Status = 5
+func (t *TaskResult) Cancel(reason string) *TaskResult {
+	t.Transition(StatusCancelled, reason)
 	return t
 }
 
 func (t *TaskResult) Fail(err error) *TaskResult {
-	t.FinishedAt = time.Now()
-	t.Status = 10
+	return t.end(StatusFailed, err)
+}
+
+func (t *TaskResult) TimeOut(err error) *TaskResult {
+	return t.end(StatusTimedOut, err)
+}
+
+func (t *TaskResult) end(status TaskStatus, err error) *TaskResult {
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+
 	t.Error = err
+	if terr := t.Transition(status, reason); terr != nil {
+		t.Error = errors.Join(err, terr)
+	}
 	return t
 }
 
-func (t *TaskResult) Skip() *TaskResult {
-	t.Status = 2
+func (t *TaskResult) Skip(reason string) *TaskResult {
+	t.Transition(StatusSkipped, reason)
 	return t
 }
 
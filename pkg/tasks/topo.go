@@ -0,0 +1,73 @@
+package tasks
+
+// TopologicalSort orders the tasks so that every task comes after all of its
+// needs. The order is the concatenation of Levels.
+func (o *TaskMap) TopologicalSort() ([]*Task, error) {
+	levels, err := o.Levels()
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]*Task, 0, len(o.order))
+	for _, level := range levels {
+		order = append(order, level...)
+	}
+
+	return order, nil
+}
+
+// Levels groups the tasks into sets that can run in parallel: level 0 holds
+// tasks without needs and every other task sits one level after the deepest
+// task it needs. Tasks within a level keep their declaration order.
+//
+// Levels runs Kahn's algorithm in O(tasks + needs). A MissingDependencyError
+// or CycleError is returned when the needs do not form a DAG.
+func (o *TaskMap) Levels() ([][]*Task, error) {
+	if err := o.MissingDependencies(); err != nil {
+		return nil, err
+	}
+
+	indegree := make(map[string]int, len(o.order))
+	children := make(map[string][]string, len(o.order))
+	for _, id := range o.order {
+		for _, dep := range o.tasks[id].Needs {
+			indegree[id]++
+			children[dep] = append(children[dep], id)
+		}
+	}
+
+	queue := make([]string, 0, len(o.order))
+	for _, id := range o.order {
+		if indegree[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	level := make(map[string]int, len(o.order))
+	depth := 0
+	for i := 0; i < len(queue); i++ {
+		id := queue[i]
+		depth = max(depth, level[id]+1)
+		for _, child := range children[id] {
+			level[child] = max(level[child], level[id]+1)
+			indegree[child]--
+			if indegree[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	if len(queue) < len(o.order) {
+		if err := o.CyclicDependencies(); err != nil {
+			return nil, err
+		}
+	}
+
+	// bucketing in declaration order keeps each level stable without a sort.
+	levels := make([][]*Task, depth)
+	for _, id := range o.order {
+		levels[level[id]] = append(levels[level[id]], o.tasks[id])
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,176 @@
+package tasks
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+type taskSpec struct {
+	id    string
+	needs []string
+}
+
+func newTaskMap(specs ...taskSpec) *TaskMap {
+	m := &TaskMap{}
+	for _, spec := range specs {
+		m.Add(spec.id, &Task{Id: spec.id, Needs: spec.needs})
+	}
+
+	return m
+}
+
+func ids(tasks []*Task) []string {
+	result := make([]string, len(tasks))
+	for i, task := range tasks {
+		result[i] = task.Id
+	}
+
+	return result
+}
+
+func TestTopologicalSort(t *testing.T) {
+	tests := []struct {
+		name   string
+		specs  []taskSpec
+		order  []string
+		levels [][]string
+	}{
+		{
+			name:   "empty",
+			order:  []string{},
+			levels: [][]string{},
+		},
+		{
+			name:   "independent tasks keep declaration order",
+			specs:  []taskSpec{{"c", nil}, {"a", nil}, {"b", nil}},
+			order:  []string{"c", "a", "b"},
+			levels: [][]string{{"c", "a", "b"}},
+		},
+		{
+			name:   "needs declared later",
+			specs:  []taskSpec{{"deploy", []string{"test"}}, {"test", []string{"build"}}, {"build", nil}},
+			order:  []string{"build", "test", "deploy"},
+			levels: [][]string{{"build"}, {"test"}, {"deploy"}},
+		},
+		{
+			name: "diamond",
+			specs: []taskSpec{
+				{"a", nil},
+				{"c", []string{"a"}},
+				{"b", []string{"a"}},
+				{"d", []string{"b", "c"}},
+			},
+			order:  []string{"a", "c", "b", "d"},
+			levels: [][]string{{"a"}, {"c", "b"}, {"d"}},
+		},
+		{
+			name: "task sits after its deepest need",
+			specs: []taskSpec{
+				{"lint", nil},
+				{"build", nil},
+				{"test", []string{"build"}},
+				{"publish", []string{"lint", "test"}},
+				{"docs", []string{"lint"}},
+			},
+			order:  []string{"lint", "build", "test", "docs", "publish"},
+			levels: [][]string{{"lint", "build"}, {"test", "docs"}, {"publish"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTaskMap(tt.specs...)
+			order, err := m.TopologicalSort()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ids(order); !reflect.DeepEqual(got, tt.order) {
+				t.Errorf("got order %v, want %v", got, tt.order)
+			}
+
+			levels, err := m.Levels()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([][]string, len(levels))
+			for i, level := range levels {
+				got[i] = ids(level)
+			}
+
+			if !reflect.DeepEqual(got, tt.levels) {
+				t.Errorf("got levels %v, want %v", got, tt.levels)
+			}
+		})
+	}
+}
+
+func TestTopologicalSortErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []taskSpec
+		err   interface{}
+	}{
+		{"missing need", []taskSpec{{"a", []string{"b"}}}, new(*MissingDependencyError)},
+		{"self need", []taskSpec{{"a", []string{"a"}}}, new(*CycleError)},
+		{"cycle", []taskSpec{{"a", []string{"c"}}, {"b", []string{"a"}}, {"c", []string{"b"}}}, new(*CycleError)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTaskMap(tt.specs...).TopologicalSort()
+			if !errors.As(err, tt.err) {
+				t.Errorf("got %v, want %T", err, tt.err)
+			}
+		})
+	}
+}
+
+// generateDAG declares n tasks in shuffled order, each needing up to three
+// tasks with a lower number.
+func generateDAG(n int) *TaskMap {
+	rng := rand.New(rand.NewSource(1))
+	specs := make([]taskSpec, n)
+	for i := range specs {
+		spec := taskSpec{id: "task" + strconv.Itoa(i)}
+		for j := 0; i > 0 && j < 3; j++ {
+			need := "task" + strconv.Itoa(rng.Intn(i))
+			if !slices.Contains(spec.needs, need) {
+				spec.needs = append(spec.needs, need)
+			}
+		}
+
+		specs[i] = spec
+	}
+
+	rng.Shuffle(n, func(i, j int) {
+		specs[i], specs[j] = specs[j], specs[i]
+	})
+
+	return newTaskMap(specs...)
+}
+
+func BenchmarkTopologicalSort(b *testing.B) {
+	m := generateDAG(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.TopologicalSort(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLevels(b *testing.B) {
+	m := generateDAG(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Levels(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/go-jolt9/pkg/expr"
+	"gopkg.in/yaml.v3"
+)
+
+type Workflow struct {
+	Name        string
+	Expressions string
+	Timeout     time.Duration
+	OnFailure   FailurePolicy
+	Tasks       TaskMap
+
+	// Warnings lists the outputs references that no dependency could be
+	// inferred for while loading.
+	Warnings []DependencyWarning
+
+	dialect expr.Dialect
+	source  yamlSource
+}
+
+// NewWorkflow creates a workflow whose expressions are compiled with the
+// named dialect, overriding any `expressions` key in the file. An empty name
+// leaves the choice to the file.
+func NewWorkflow(dialect string) (*Workflow, error) {
+	w := &Workflow{}
+	if dialect == "" {
+		return w, nil
+	}
+
+	d, err := expr.NewDialect(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Expressions = dialect
+	w.dialect = d
+	return w, nil
+}
+
+// LoadWorkflow reads a workflow file. See NewWorkflow for how dialect is used.
+func LoadWorkflow(path string, dialect string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := ParseWorkflow(data, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return w, nil
+}
+
+// ParseWorkflow decodes a workflow. Unlike yaml.Unmarshal into a Workflow,
+// it keeps the source at hand so that errors inside block scalars point at
+// the right column.
+func ParseWorkflow(data []byte, dialect string) (*Workflow, error) {
+	w, err := NewWorkflow(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	w.source = strings.Split(string(data), "\n")
+	if err := yaml.Unmarshal(data, w); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Workflow) Dialect() expr.Dialect {
+	if w.dialect == nil {
+		d, _ := expr.NewDialect(expr.DefaultDialect)
+		w.dialect = d
+	}
+
+	return w.dialect
+}
+
+// NewExecutor returns an executor for the workflow's tasks that applies its
+// timeout and failure policy.
+func (w *Workflow) NewExecutor(registry *TaskRegistry, resolve DelegateResolver) *Executor {
+	e := NewExecutor(&w.Tasks, registry, resolve)
+	e.Timeout = w.Timeout
+	e.OnFailure = w.OnFailure
+	return e
+}
+
+func (w *Workflow) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("workflow must be a mapping on line %d at column %d", node.Line, node.Column)
+	}
+
+	// the dialect has to be known before any task expression is compiled, so
+	// it is read ahead of the remaining keys.
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+		if keyNode.Value != "expressions" || w.dialect != nil {
+			continue
+		}
+
+		d, err := expr.NewDialect(valueNode.Value)
+		if err != nil {
+			return fmt.Errorf("%s on line %d at column %d", err.Error(), valueNode.Line, valueNode.Column)
+		}
+
+		w.Expressions = valueNode.Value
+		w.dialect = d
+	}
+
+	dialect := w.Dialect()
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+		key := keyNode.Value
+
+		switch key {
+		case "name":
+			w.Name = valueNode.Value
+		case "expressions":
+		case "timeout":
+			timeout, err := ParseDuration(valueNode.Value)
+			if err != nil {
+				return fmt.Errorf("timeout must be a number of seconds or a duration such as 90s or 1h30m on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			w.Timeout = timeout
+		case "on-failure":
+			policy, err := ParseFailurePolicy(valueNode.Value)
+			if err != nil {
+				return fmt.Errorf("on-failure must be fail-fast or continue on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+			w.OnFailure = policy
+		case "tasks":
+			switch valueNode.Kind {
+			case yaml.SequenceNode:
+				for _, n := range valueNode.Content {
+					task := &Task{dialect: dialect, source: w.source}
+					if err := n.Decode(task); err != nil {
+						return err
+					}
+
+					if task.Id == "" {
+						return fmt.Errorf("task id is required on line %d at column %d", n.Line, n.Column)
+					}
+
+					if !w.Tasks.Add(task.Id, task) {
+						return fmt.Errorf("duplicate task %s on line %d at column %d", task.Id, n.Line, n.Column)
+					}
+				}
+
+			case yaml.MappingNode:
+				for i := 0; i < len(valueNode.Content); i += 2 {
+					kn := valueNode.Content[i]
+					vn := valueNode.Content[i+1]
+
+					task := &Task{dialect: dialect, source: w.source}
+					if err := vn.Decode(task); err != nil {
+						return err
+					}
+
+					if task.Id == "" {
+						task.Id = kn.Value
+					}
+
+					if !w.Tasks.Add(task.Id, task) {
+						return fmt.Errorf("duplicate task %s on line %d at column %d", task.Id, kn.Line, kn.Column)
+					}
+				}
+
+			default:
+				return fmt.Errorf("tasks must be a sequence or mapping on line %d at column %d", valueNode.Line, valueNode.Column)
+			}
+
+		default:
+			return fmt.Errorf("unknown key %s on line %d at column %d", key, keyNode.Line, keyNode.Column)
+		}
+	}
+
+	// needs are inferred before matrices are expanded, so that instances
+	// inherit them and references to a matrix task reach every instance.
+	w.Warnings = w.Tasks.InferDependencies()
+	return w.Tasks.ExpandMatrices()
+}
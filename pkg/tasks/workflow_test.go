@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWorkflowExpressionPositions(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "plain",
+			yaml: "tasks:\n  a:\n    run: echo ${{ 1 + 2 }}\n",
+			want: "on line 3 at column 21",
+		},
+		{
+			name: "double quoted",
+			yaml: "tasks:\n  a:\n    run: \"echo ${{ 1 + 2 }}\"\n",
+			want: "on line 3 at column 22",
+		},
+		{
+			name: "literal",
+			yaml: "tasks:\n  a:\n    run: |\n      echo hi\n      echo ${{ 1 + 2 }}\n",
+			want: "on line 5 at column 18",
+		},
+		{
+			name: "literal indented further",
+			yaml: "tasks:\n  a:\n    run: |\n            echo ${{ 1 + 2 }}\n",
+			want: "on line 4 at column 24",
+		},
+		{
+			name: "literal with blank lines",
+			yaml: "tasks:\n  a:\n    run: |\n      echo hi\n\n        echo ${{ 1 + 2 }}\n",
+			want: "on line 6 at column 20",
+		},
+		{
+			name: "folded",
+			yaml: "tasks:\n  a:\n    if: >-\n      ${{ true &&\n        1 + 2 }}\n",
+			want: "on line 5 at column 11",
+		},
+		{
+			name: "folded with a paragraph break",
+			yaml: "tasks:\n  a:\n    run: >\n      echo a\n\n      echo ${{ 1 + 2 }}\n",
+			want: "on line 6 at column 18",
+		},
+		{
+			name: "env",
+			yaml: "tasks:\n  a:\n    env:\n      A: |\n        ${{ 1 + 2 }}\n",
+			want: "on line 5 at column 15",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWorkflow([]byte(tt.yaml), "")
+			if err == nil {
+				t.Fatal("ParseWorkflow succeeded, want a syntax error")
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseWorkflow error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWorkflowRejectsSecretsInSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"if", "if: ${{ secrets.TOKEN == 'x' }}", "if cannot read secrets on line 3"},
+		{"timeout", "timeout: ${{ secrets.TIMEOUT }}", "timeout cannot read secrets on line 3"},
+		{"cwd", "cwd: ${{ toJSON(secrets) }}", "cwd cannot read secrets on line 3"},
+		{"retry-on", "retry-on: ${{ secrets.RETRY }}", "retry-on cannot read secrets on line 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWorkflow([]byte("tasks:\n  a:\n    "+tt.yaml+"\n"), "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseWorkflow error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseWorkflow([]byte("tasks:\n  a:\n    run: echo ${{ secrets.TOKEN }}\n"), ""); err != nil {
+		t.Errorf("run cannot read secrets: %v", err)
+	}
+}